@@ -0,0 +1,509 @@
+package object
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+AccessRuleT is a parsed "access" of a "limit" entry:
+
+	rule    := "*" | or
+	or      := and {OR and}
+	and     := factor {AND factor}
+	factor  := "(" or ")" | NOT factor | operand op operand | property [NOT] IN "(" operand {"," operand} ")"
+	op      := "=" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+	operand := property | number | 'string' | session@name | TODAYUNIX
+
+"∈" stands for IN, keywords are case insensitive. "*" allows every row.
+*/
+type AccessRuleT struct {
+	Text string
+	all  bool
+	root *ruleNodeT
+}
+
+type ruleNodeT struct {
+	kind     string //and, or, not, compare, in, notin
+	op       string
+	children []*ruleNodeT
+	operands []ruleOperandT //compare: left, right; in: property, values
+}
+
+type ruleOperandT struct {
+	kind  string //property, number, string, session, today
+	value string
+}
+
+type ruleTokenT struct {
+	kind  string //word, number, string, op, (, ), ","
+	value string
+}
+
+var ruleOperators = []string{"!=", "<>", "<=", ">=", "=", "<", ">"}
+
+func tokenizeRule(txt string) (tokens []ruleTokenT, e error) {
+	rr := []rune(txt)
+	for i := 0; i < len(rr); {
+		r := rr[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, ruleTokenT{string(r), string(r)})
+			i++
+		case r == '∈':
+			tokens = append(tokens, ruleTokenT{"word", "IN"})
+			i++
+		case r == '\'':
+			s, j := "", i+1
+			for ; j < len(rr); j++ {
+				if rr[j] == '\'' {
+					if j+1 < len(rr) && rr[j+1] == '\'' {
+						s += "'"
+						j++
+						continue
+					}
+					break
+				}
+				s += string(rr[j])
+			}
+			if j >= len(rr) {
+				e = errors.New("unterminated string at " + string(rr[i:]))
+				return
+			}
+			tokens = append(tokens, ruleTokenT{"string", s})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rr) && unicode.IsDigit(rr[i+1])):
+			j := i + 1
+			for j < len(rr) && (unicode.IsDigit(rr[j]) || rr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleTokenT{"number", string(rr[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(rr) && (unicode.IsLetter(rr[j]) || unicode.IsDigit(rr[j]) || rr[j] == '_' || rr[j] == '@' || rr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, ruleTokenT{"word", string(rr[i:j])})
+			i = j
+		default:
+			op := ""
+			for _, o := range ruleOperators {
+				if strings.HasPrefix(string(rr[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if len(op) == 0 {
+				e = errors.New("unexpected " + string(r) + " at " + string(rr[i:]))
+				return
+			}
+			tokens = append(tokens, ruleTokenT{"op", op})
+			i += len([]rune(op))
+		}
+	}
+	return
+}
+
+type ruleParserT struct {
+	tokens []ruleTokenT
+	i      int
+}
+
+func (p *ruleParserT) peek() (t ruleTokenT, ok bool) {
+	if p.i < len(p.tokens) {
+		t, ok = p.tokens[p.i], true
+	}
+	return
+}
+
+func (p *ruleParserT) keyword(word string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == "word" && strings.EqualFold(t.value, word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParserT) expect(kind string) (e error) {
+	if t, ok := p.peek(); ok && t.kind == kind {
+		p.i++
+	} else {
+		e = p.unexpected(kind)
+	}
+	return
+}
+
+func (p *ruleParserT) unexpected(want string) error {
+	if t, ok := p.peek(); ok {
+		return errors.New(want + " expected at " + t.value)
+	}
+	return errors.New(want + " expected at end")
+}
+
+func (p *ruleParserT) or() (node *ruleNodeT, e error) {
+	if node, e = p.and(); e != nil {
+		return
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			e = err
+			return
+		}
+		node = &ruleNodeT{kind: "or", children: []*ruleNodeT{node, right}}
+	}
+	return
+}
+
+func (p *ruleParserT) and() (node *ruleNodeT, e error) {
+	if node, e = p.factor(); e != nil {
+		return
+	}
+	for p.keyword("AND") {
+		right, err := p.factor()
+		if err != nil {
+			e = err
+			return
+		}
+		node = &ruleNodeT{kind: "and", children: []*ruleNodeT{node, right}}
+	}
+	return
+}
+
+func (p *ruleParserT) factor() (node *ruleNodeT, e error) {
+	if t, ok := p.peek(); ok && t.kind == "(" {
+		p.i++
+		if node, e = p.or(); e == nil {
+			e = p.expect(")")
+		}
+		return
+	}
+	if p.keyword("NOT") {
+		child, err := p.factor()
+		if err != nil {
+			e = err
+			return
+		}
+		node = &ruleNodeT{kind: "not", children: []*ruleNodeT{child}}
+		return
+	}
+	left, err := p.operand()
+	if err != nil {
+		e = err
+		return
+	}
+	kind := "in"
+	if p.keyword("NOT") {
+		kind = "notin"
+		if !p.keyword("IN") {
+			e = p.unexpected("IN")
+			return
+		}
+	} else if !p.keyword("IN") {
+		t, ok := p.peek()
+		if !ok || t.kind != "op" {
+			e = p.unexpected("operator")
+			return
+		}
+		p.i++
+		right, err := p.operand()
+		if err != nil {
+			e = err
+			return
+		}
+		node = &ruleNodeT{kind: "compare", op: t.value, operands: []ruleOperandT{left, right}}
+		return
+	}
+	if left.kind != "property" {
+		e = errors.New("IN needs a property on the left: " + left.value)
+		return
+	}
+	node = &ruleNodeT{kind: kind, operands: []ruleOperandT{left}}
+	if e = p.expect("("); e != nil {
+		return
+	}
+	for {
+		v, err := p.operand()
+		if err != nil {
+			e = err
+			return
+		}
+		node.operands = append(node.operands, v)
+		if t, ok := p.peek(); ok && t.kind == "," {
+			p.i++
+			continue
+		}
+		e = p.expect(")")
+		return
+	}
+}
+
+func (p *ruleParserT) operand() (op ruleOperandT, e error) {
+	t, ok := p.peek()
+	if !ok {
+		e = p.unexpected("operand")
+		return
+	}
+	switch t.kind {
+	case "number":
+		if _, err := strconv.ParseFloat(t.value, 64); err != nil {
+			e = errors.New("invalid number: " + t.value)
+			return
+		}
+		op = ruleOperandT{"number", t.value}
+	case "string":
+		op = ruleOperandT{"string", t.value}
+	case "word":
+		switch upper := strings.ToUpper(t.value); {
+		case upper == "TODAYUNIX":
+			op = ruleOperandT{"today", t.value}
+		case strings.HasPrefix(t.value, "session@"):
+			name := strings.TrimPrefix(t.value, "session@")
+			if !identifierRegex.MatchString(name) {
+				e = errors.New("invalid session variable: " + t.value)
+				return
+			}
+			op = ruleOperandT{"session", name}
+		case upper == "AND" || upper == "OR" || upper == "NOT" || upper == "IN":
+			e = p.unexpected("operand")
+			return
+		default:
+			if !identifierRegex.MatchString(t.value) {
+				e = errors.New("invalid property: " + t.value)
+				return
+			}
+			op = ruleOperandT{"property", t.value}
+		}
+	default:
+		e = p.unexpected("operand")
+		return
+	}
+	p.i++
+	return
+}
+
+// ParseAccessRule parses the "access" text of a limit entry.
+func ParseAccessRule(txt string) (rule *AccessRuleT, e error) {
+	rule = &AccessRuleT{Text: txt}
+	if strings.TrimSpace(txt) == "*" {
+		rule.all = true
+		return
+	}
+	tokens, err := tokenizeRule(txt)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(tokens) == 0 {
+		e = errors.New("empty access rule")
+		return
+	}
+	p := ruleParserT{tokens: tokens}
+	if rule.root, e = p.or(); e == nil && p.i < len(tokens) {
+		e = p.unexpected("AND/OR")
+	}
+	return
+}
+
+// Properties: the properties the rule refers to.
+func (rule *AccessRuleT) Properties() (properties []string) {
+	var walk func(node *ruleNodeT)
+	walk = func(node *ruleNodeT) {
+		for _, op := range node.operands {
+			if exists, _ := base.In_array(op.value, properties); op.kind == "property" && !exists {
+				properties = append(properties, op.value)
+			}
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	if rule.root != nil {
+		walk(rule.root)
+	}
+	return
+}
+
+/*
+Where compiles the rule to a WHERE condition with placeholders of db_type, empty for "*".
+Properties are qualified by alias if any, session@ variables take their values from session.
+*/
+func (rule *AccessRuleT) Where(db_type int, alias string, session map[string]any) (where string, args []any, e error) {
+	sa := sqlArgsT{db_type: db_type}
+	where, e = rule.where(&sa, alias, session)
+	args = sa.args
+	return
+}
+
+func (rule *AccessRuleT) where(sa *sqlArgsT, alias string, session map[string]any) (where string, e error) {
+	if rule.all {
+		return
+	}
+	where, e = rule.root.sql(sa, alias, session)
+	return
+}
+
+func (node *ruleNodeT) sql(sa *sqlArgsT, alias string, session map[string]any) (txt string, e error) {
+	switch node.kind {
+	case "and", "or":
+		left, err := node.children[0].sql(sa, alias, session)
+		if err != nil {
+			e = err
+			return
+		}
+		right, err := node.children[1].sql(sa, alias, session)
+		if err != nil {
+			e = err
+			return
+		}
+		txt = "(" + left + " " + strings.ToUpper(node.kind) + " " + right + ")"
+	case "not":
+		child, err := node.children[0].sql(sa, alias, session)
+		if err != nil {
+			e = err
+			return
+		}
+		txt = "NOT " + child
+	case "compare":
+		op := node.op
+		if op == "<>" {
+			op = "!="
+		}
+		ss := []string{}
+		for _, operand := range node.operands {
+			s, err := operand.sql(sa, alias, session)
+			if err != nil {
+				e = err
+				return
+			}
+			ss = append(ss, s)
+		}
+		txt = ss[0] + op + ss[1]
+	case "in", "notin":
+		ss := []string{}
+		for _, operand := range node.operands {
+			s, err := operand.sql(sa, alias, session)
+			if err != nil {
+				e = err
+				return
+			}
+			ss = append(ss, s)
+		}
+		in := " IN "
+		if node.kind == "notin" {
+			in = " NOT IN "
+		}
+		txt = ss[0] + in + "(" + strings.Join(ss[1:], ",") + ")"
+	}
+	return
+}
+
+func (op ruleOperandT) sql(sa *sqlArgsT, alias string, session map[string]any) (txt string, e error) {
+	switch op.kind {
+	case "property":
+		txt = sqlIdentifier(op.value, sa.db_type)
+		if len(alias) > 0 {
+			txt = alias + "." + txt
+		}
+	case "number": //checked by the parser, inlined so that "0=1" needs no parameter types
+		txt = op.value
+	case "string":
+		txt = sa.bind(op.value)
+	case "today":
+		txt = sa.bind(int64(base.Str2int(base.TodayUnixtime())))
+	case "session":
+		v, ok := session[op.value]
+		if !ok {
+			e = errors.New("session@" + op.value + " not set")
+			return
+		}
+		txt = sa.bind(v)
+	}
+	return
+}
+
+// AccessLimitT is an entry of a "limit" array: the usertypes it applies to ("all" for every usertype) and its rule.
+type AccessLimitT struct {
+	Usertypes []string
+	Rule      *AccessRuleT
+}
+
+// ParseAccessLimits parses a "limit" array, the first invalid rule is the error.
+func ParseAccessLimits(limit gjson.Result) (limits []AccessLimitT, e error) {
+	for i, row := range limit.Array() {
+		rule, err := ParseAccessRule(row.Get("access").String())
+		if err != nil {
+			e = errors.New("limit." + strconv.Itoa(i) + ".access: " + err.Error())
+			return
+		}
+		limits = append(limits, AccessLimitT{strings.Split(row.Get("usertype").String(), ","), rule})
+	}
+	return
+}
+
+// checkAccessLimits: the "limit" rules of o and of its sub-objects parse, the first invalid one is the error.
+func checkAccessLimits(o gjson.Result, roadmap []string) (e error) {
+	if limit := o.Get("limit"); limit.Exists() {
+		if _, err := ParseAccessLimits(limit); err != nil {
+			e = errors.New(strings.Join(roadmap, ".") + "." + err.Error())
+			return
+		}
+	}
+	o.ForEach(func(k, v gjson.Result) bool {
+		if v.IsObject() && strings.HasPrefix(v.Get("type").String(), "object") {
+			e = checkAccessLimits(v, append(append([]string{}, roadmap...), k.String()))
+		}
+		return e == nil
+	})
+	return
+}
+
+/*
+UserAccessWhere is UserAccess with the rule language: the WHERE condition of the first limit entry for user_type,
+"0=1" when no entry applies and empty without a "limit" array.
+*/
+func UserAccessWhere(user_type int, limit gjson.Result, db_type int, alias string, session map[string]any) (where string, args []any, e error) {
+	sa := sqlArgsT{db_type: db_type}
+	where, e = userAccessWhere(&sa, user_type, limit, alias, session)
+	args = sa.args
+	return
+}
+
+// userAccessWhere binds the arguments of UserAccessWhere to sa, none when the rule fails.
+func userAccessWhere(sa *sqlArgsT, user_type int, limit gjson.Result, alias string, session map[string]any) (where string, e error) {
+	if !limit.IsArray() {
+		return
+	}
+	limits, err := ParseAccessLimits(limit)
+	if err != nil {
+		e = err
+		return
+	}
+	where = "0=1" //no access authority by default
+	usertype := base.GetUsertype(user_type)
+	for _, l := range limits {
+		all, _ := base.In_array("all", l.Usertypes)
+		mine, _ := base.In_array(usertype, l.Usertypes)
+		if all || mine {
+			n := len(sa.args)
+			w, err := l.Rule.where(sa, alias, session)
+			if err != nil {
+				sa.args = sa.args[:n]
+				e = err
+			} else {
+				where = w
+			}
+			break
+		}
+	}
+	return
+}
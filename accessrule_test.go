@@ -0,0 +1,142 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+func TestAccessRuleWhere(t *testing.T) {
+	session := map[string]any{"user_id": int64(7), "dept": "R&D"}
+	tests := []struct {
+		rule  string
+		where string //MySQL, alias o
+		args  []any
+		pg    string //PostgreSQL, no alias
+	}{
+		{"*", "", nil, ""},
+		{"a=1 OR b=2 AND c=3", "(o.`a`=1 OR (o.`b`=2 AND o.`c`=3))", nil, `("a"=1 OR ("b"=2 AND "c"=3))`},
+		{"(a=1 or b=2) and c=3", "((o.`a`=1 OR o.`b`=2) AND o.`c`=3)", nil, `(("a"=1 OR "b"=2) AND "c"=3)`},
+		{"NOT a=1 AND b<>2", "(NOT o.`a`=1 AND o.`b`!=2)", nil, `(NOT "a"=1 AND "b"!=2)`},
+		{"creator_id=session@user_id", "o.`creator_id`=?", []any{int64(7)}, `"creator_id"=$1`},
+		{"dept=session@dept AND title='it''s' ", "(o.`dept`=? AND o.`title`=?)", []any{"R&D", "it's"}, `("dept"=$1 AND "title"=$2)`},
+		{"status IN (1, 2, 'x')", "o.`status` IN (1,2,?)", []any{"x"}, `"status" IN (1,2,$1)`},
+		{"status not in ('a','b')", "o.`status` NOT IN (?,?)", []any{"a", "b"}, `"status" NOT IN ($1,$2)`},
+		{"status ∈ (3)", "o.`status` IN (3)", nil, `"status" IN (3)`},
+		{"x >= -1.5 AND y <= z", "(o.`x`>=-1.5 AND o.`y`<=o.`z`)", nil, `("x">=-1.5 AND "y"<="z")`},
+		{"0=1", "0=1", nil, "0=1"},
+		{"name='a; DROP TABLE t'", "o.`name`=?", []any{"a; DROP TABLE t"}, `"name"=$1`},
+	}
+	for _, tt := range tests {
+		rule, e := ParseAccessRule(tt.rule)
+		if e != nil {
+			t.Errorf("ParseAccessRule(%q): %v", tt.rule, e)
+			continue
+		}
+		where, args, e := rule.Where(base.MySQL, "o", session)
+		if e != nil || where != tt.where || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q MySQL = %q %v %v, want %q %v", tt.rule, where, args, e, tt.where, tt.args)
+		}
+		where, args, e = rule.Where(PostgreSQL, "", session)
+		if e != nil || where != tt.pg || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q PostgreSQL = %q %v %v, want %q %v", tt.rule, where, args, e, tt.pg, tt.args)
+		}
+	}
+}
+
+func TestAccessRuleSyntax(t *testing.T) {
+	for _, txt := range []string{
+		"",
+		"a=",
+		"a=1 AND",
+		"(a=1",
+		"a=1)",
+		"a 1",
+		"a=1 b=2",
+		"title='open",
+		"a=1; DROP TABLE t",
+		"1 IN (1,2)",
+		"a IN 1",
+		"a IN (1,",
+		"a NOT 1",
+		"a=session@",
+		"a=session@x-y",
+		"a=b.c",
+		"AND=1",
+		"a=1.2.3",
+		"a ! b",
+	} {
+		if _, e := ParseAccessRule(txt); e == nil {
+			t.Errorf("ParseAccessRule(%q): no error", txt)
+		}
+	}
+}
+
+func TestAccessRuleProperties(t *testing.T) {
+	rule, e := ParseAccessRule("a=session@user_id OR (b IN (1,2) AND a<c) OR TODAYUNIX>d")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got, want := rule.Properties(), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties = %q, want %q", got, want)
+	}
+	if _, _, e = rule.Where(base.MySQL, "", map[string]any{}); e == nil {
+		t.Error("session@user_id not set: no error")
+	}
+}
+
+func TestUserAccessWhere(t *testing.T) {
+	session := map[string]any{"user_id": 7}
+	where, args, e := UserAccessWhere(-1, gjson.Result{}, PostgreSQL, "o", session)
+	if e != nil || where != "" || args != nil {
+		t.Errorf("no limit = %q %v %v", where, args, e)
+	}
+	limit := gjson.Parse(`[{"usertype":"nosuchtype","access":"*"},{"usertype":"all","access":"creator_id=session@user_id AND kind='x'"}]`)
+	where, args, e = UserAccessWhere(-1, limit, PostgreSQL, "o", session)
+	if e != nil || where != `(o."creator_id"=$1 AND o."kind"=$2)` || !reflect.DeepEqual(args, []any{7, "x"}) {
+		t.Errorf("all = %q %v %v", where, args, e)
+	}
+	limit = gjson.Parse(`[{"usertype":"nosuchtype","access":"*"}]`)
+	if where, _, e = UserAccessWhere(-1, limit, PostgreSQL, "o", session); e != nil || where != "0=1" {
+		t.Errorf("no entry = %q %v", where, e)
+	}
+	limit = gjson.Parse(`[{"usertype":"all","access":"a=session@missing"}]`)
+	sa := sqlArgsT{db_type: PostgreSQL}
+	sa.bind("language")
+	if _, e = userAccessWhere(&sa, -1, limit, "o", session); e == nil || len(sa.args) != 1 {
+		t.Errorf("failing rule = %v, args %v", e, sa.args)
+	}
+	limit = gjson.Parse(`[{"usertype":"all","access":"a=("}]`)
+	if _, _, e = UserAccessWhere(-1, limit, PostgreSQL, "o", session); e == nil {
+		t.Error("invalid rule: no error")
+	}
+}
+
+func TestAccessLimitsAtLoad(t *testing.T) {
+	valid := `{"book":{"type":"object","title":{"type":"string"},"limit":[{"usertype":"all","access":"title='x'"}],
+		"chapter":{"type":"object","title":{"type":"string"},"limit":[{"usertype":"guest","access":"*"}]}}}`
+	invalid := `{"book":{"type":"object","title":{"type":"string"},"limit":[{"usertype":"all","access":"*"}],
+		"chapter":{"type":"object","title":{"type":"string"},"limit":[{"usertype":"all","access":"*"},{"usertype":"guest","access":"title=("}]}}}`
+	if _, e := ParseDefinition([]byte(valid), "book"); e != nil {
+		t.Errorf("ParseDefinition(valid): %v", e)
+	}
+	if _, _, e := DefinitionExtend([]byte(valid), "book", "", "", "", false); e != nil {
+		t.Errorf("DefinitionExtend(valid): %v", e)
+	}
+	if _, e := NewRepository(nil, base.SQLite, valid, "book"); e != nil {
+		t.Errorf("NewRepository(valid): %v", e)
+	}
+	od, e := ParseDefinition([]byte(invalid), "book")
+	if od != nil || e == nil || !strings.HasPrefix(e.Error(), "book.chapter.limit.1.access: ") {
+		t.Errorf("ParseDefinition(invalid) = %v", e)
+	}
+	if _, _, e = DefinitionExtend([]byte(invalid), "book", "", "", "", false); e == nil || !strings.HasPrefix(e.Error(), "book.chapter.limit.1.access: ") {
+		t.Errorf("DefinitionExtend(invalid) = %v", e)
+	}
+	if _, e = NewRepository(nil, base.SQLite, invalid, "book"); e == nil || !strings.HasPrefix(e.Error(), "book.chapter.limit.1.access: ") {
+		t.Errorf("NewRepository(invalid) = %v", e)
+	}
+}
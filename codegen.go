@@ -0,0 +1,110 @@
+package object
+
+import (
+	"errors"
+	"go/format"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+var goTypes = map[string]string{
+	"int":      "int",
+	"long":     "int64",
+	"float":    "float64",
+	"decimal":  "string", //exact, float64 would round
+	"time":     "time.Time",
+	"string":   "string",
+	"password": "string",
+	"ipv4":     "string",
+	"ipv6":     "string",
+	"dotids":   "string",
+	"text":     "string",
+	"blob":     "[]byte",
+}
+
+var goInitialisms = []string{"id", "ip", "ipv4", "ipv6", "url", "uuid", "json", "html", "sql"}
+
+// oneLine: s for a // comment, line breaks as spaces.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)
+}
+
+// goName: article_comments -> ArticleComments, parent_id -> ParentID.
+func goName(name string) (gn string) {
+	for _, w := range strings.Split(name, "_") {
+		if len(w) == 0 {
+			continue
+		}
+		initialism := false
+		for _, i := range goInitialisms {
+			if w == i {
+				initialism = true
+				break
+			}
+		}
+		if initialism {
+			gn += strings.ToUpper(w)
+		} else {
+			gn += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return
+}
+
+/*
+Definition2Go generates a Go struct per table of an extended definition (DefinitionExtend output),
+the <identifier>_languages table included, sub-objects become slices of their structs.
+A decimal column is a string, keeping its exact value.
+*/
+func Definition2Go(definition, identifier, pkgname string) (src []byte, e error) {
+	o := gjson.Get(definition, identifier)
+	if !o.Exists() {
+		e = errors.New(identifier + " syntax error!")
+		return
+	}
+	tables := []tableT{}
+	collectTables(o, []string{identifier}, &tables)
+	usetime := false
+	body := ""
+	for _, t := range tables {
+		tablename := strings.Join(t.roadmap, "_")
+		body += "\n// " + goName(tablename) + " is a row of table " + tablename + "."
+		if caption := t.o.Get("caption").String(); len(caption) > 0 {
+			body += "\n// " + oneLine(caption)
+		}
+		body += "\ntype " + goName(tablename) + " struct {\n"
+		t.o.ForEach(func(k, v gjson.Result) bool {
+			name := k.String()
+			if v.IsObject() {
+				v_type := v.Get("type").String()
+				if strings.HasPrefix(v_type, "object") {
+					body += "\t" + goName(name) + " []" + goName(tablename+"_"+name) + " `db:\"-\" json:\"" + name + ",omitempty\"`\n"
+				} else {
+					gotype, ok := goTypes[v_type]
+					if !ok {
+						gotype = "string"
+					}
+					if gotype == "time.Time" {
+						usetime = true
+					}
+					body += "\t" + goName(name) + " " + gotype + " `db:\"" + name + "\" json:\"" + name + "\"`"
+					if comment := v.Get("comment").String(); len(comment) > 0 {
+						body += " //" + oneLine(comment)
+					}
+					body += "\n"
+				}
+			}
+			return true
+		})
+		body += "}\n"
+	}
+	txt := "// Code generated by object.Definition2Go from " + identifier + ".object; DO NOT EDIT.\n\n"
+	txt += "package " + pkgname + "\n"
+	if usetime {
+		txt += "\nimport \"time\"\n"
+	}
+	txt += body
+	src, e = format.Source([]byte(txt))
+	return
+}
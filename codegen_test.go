@@ -0,0 +1,66 @@
+package object
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const codegenDefinition = `{"book":{"type":"object","caption":"en:Book\nzh:书\r\n// x",
+	"id":{"type":"int"},"title":{"type":"string","comment":"the title\nsecond line"},"price":{"type":"decimal"},"weight":{"type":"float"},
+	"views":{"type":"long"},"ip":{"type":"ipv4"},"cover":{"type":"blob"},"time_created":{"type":"time"},"other":{"type":"nosuch"},
+	"chapter":{"type":"object","id":{"type":"int"},"book_id":{"type":"int"}}}}`
+
+// the generated source, ' for a backquote
+const codegenGo = `// Code generated by object.Definition2Go from book.object; DO NOT EDIT.
+
+package model
+
+import "time"
+
+// Book is a row of table book.
+// en:Book zh:书 // x
+type Book struct {
+	ID          int           'db:"id" json:"id"'
+	Title       string        'db:"title" json:"title"' //the title second line
+	Price       string        'db:"price" json:"price"'
+	Weight      float64       'db:"weight" json:"weight"'
+	Views       int64         'db:"views" json:"views"'
+	IP          string        'db:"ip" json:"ip"'
+	Cover       []byte        'db:"cover" json:"cover"'
+	TimeCreated time.Time     'db:"time_created" json:"time_created"'
+	Other       string        'db:"other" json:"other"'
+	Chapter     []BookChapter 'db:"-" json:"chapter,omitempty"'
+}
+
+// BookChapter is a row of table book_chapter.
+type BookChapter struct {
+	ID     int 'db:"id" json:"id"'
+	BookID int 'db:"book_id" json:"book_id"'
+}
+`
+
+func TestDefinition2Go(t *testing.T) {
+	src, e := Definition2Go(codegenDefinition, "book", "model")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if want := strings.ReplaceAll(codegenGo, "'", "`"); string(src) != want {
+		t.Errorf("Definition2Go =\n%s\nwant\n%s", src, want)
+	}
+	if _, e = parser.ParseFile(token.NewFileSet(), "book.go", src, parser.ParseComments); e != nil {
+		t.Error(e)
+	}
+	if _, e = Definition2Go(codegenDefinition, "author", "model"); e == nil {
+		t.Error("missing identifier: no error")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, want := range map[string]string{"article_comments": "ArticleComments", "parent_id": "ParentID", "ipv6": "IPV6", "a__b": "AB", "url_json": "URLJSON"} {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package object
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+CodesetService manages the items of a codeset table.
+With coding_type "hierarchical" the code of an item is the code of its parent followed by a segment,
+code_structure gives the segment length of each level ("2,2,3": 01, 0102, 0102003) and pad_format the pad character ("0" by default).
+*/
+type CodesetService struct {
+	Repo         *Repository
+	Identifier   string
+	hierarchical bool //coding_type
+	segments     []int
+	pad          string
+	pattern      *regexp.Regexp
+	size         int
+}
+
+func NewCodesetService(db *sql.DB, db_type int, definition, identifier string) (cs *CodesetService, e error) {
+	o := gjson.Get(definition, identifier)
+	if o.Get("type").String() != "codeset" {
+		e = errors.New(identifier + " is not a codeset!")
+		return
+	}
+	repo, err := NewRepository(db, db_type, definition, identifier)
+	if err != nil {
+		e = err
+		return
+	}
+	cs = &CodesetService{Repo: repo, Identifier: identifier, pad: "0"}
+	cs.hierarchical = o.Get("coding_type").String() == "hierarchical"
+	if pad := o.Get("pad_format").String(); len(pad) > 0 {
+		cs.pad = pad[:1]
+	}
+	for _, s := range strings.FieldsFunc(o.Get("code_structure").String(), func(r rune) bool { return r == ',' || r == '-' || r == ' ' }) {
+		n := base.Str2int(s)
+		if n <= 0 {
+			e = errors.New(identifier + ".code_structure syntax error!")
+			return
+		}
+		cs.segments = append(cs.segments, n)
+	}
+	if cs.hierarchical && len(cs.segments) == 0 {
+		e = errors.New(identifier + ".code_structure is required by hierarchical coding!")
+		return
+	}
+	if pattern := o.Get("code.pattern").String(); len(pattern) > 0 {
+		if cs.pattern, e = regexp.Compile(pattern); e != nil {
+			return
+		}
+	}
+	cs.size = int(o.Get("code.size").Int())
+	return
+}
+
+// level: the level of a hierarchical code by its length, 0 for the first level, -1 if no level has that length.
+func (cs *CodesetService) level(code string) int {
+	length := 0
+	for i, n := range cs.segments {
+		length += n
+		if len(code) == length {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateCode checks code against the pattern and size of the code property and against code_structure.
+func (cs *CodesetService) ValidateCode(code string) (e error) {
+	if len(code) == 0 {
+		e = errors.New(cs.Identifier + ": empty code!")
+	} else if cs.pattern != nil && !cs.pattern.MatchString(code) {
+		e = errors.New(cs.Identifier + ": code " + code + " not match " + cs.pattern.String() + "!")
+	} else if cs.size > 0 && len(code) > cs.size {
+		e = errors.New(cs.Identifier + ": code " + code + " longer than " + strconv.Itoa(cs.size) + "!")
+	} else if cs.hierarchical && cs.level(code) < 0 {
+		e = errors.New(cs.Identifier + ": code " + code + " not match code_structure!")
+	}
+	return
+}
+
+/*
+NextCode is the code following the largest numeric code among the children of parentid (0: the first level).
+Without hierarchical coding it follows the largest numeric code of the codeset, padded to the first segment if any.
+*/
+func (cs *CodesetService) NextCode(parentid int64) (code string, e error) {
+	prefix, width := "", 0
+	if cs.hierarchical {
+		level := 0
+		if parentid > 0 {
+			parent, err := cs.Repo.Get(cs.Identifier, parentid)
+			if err != nil {
+				e = err
+				return
+			}
+			prefix = toString(parent["code"])
+			if level = cs.level(prefix) + 1; level == 0 {
+				e = errors.New(cs.Identifier + ": parent code " + prefix + " not match code_structure!")
+				return
+			}
+			if level >= len(cs.segments) {
+				e = errors.New(cs.Identifier + ": code_structure has no level below " + prefix + "!")
+				return
+			}
+		}
+		width = cs.segments[level]
+	} else if len(cs.segments) > 0 {
+		width = cs.segments[0]
+	}
+	sa := sqlArgsT{db_type: cs.Repo.DB_type}
+	query := "SELECT code FROM " + cs.Repo.ident(cs.Identifier)
+	if len(prefix) > 0 {
+		query += " WHERE code LIKE " + sa.bind(likeEscape(prefix)+"%") + " ESCAPE '!'"
+	}
+	rows, err := cs.Repo.query(cs.Repo.DB, cs.Repo.tables[cs.Identifier], query, sa.args...)
+	if err != nil {
+		e = err
+		return
+	}
+	max := 0
+	for _, row := range rows {
+		c := strings.TrimPrefix(toString(row["code"]), prefix)
+		if (width > 0 && cs.hierarchical && len(c) != width) || !base.IsDigital(c) {
+			continue
+		}
+		if n := base.Str2int(c); n > max {
+			max = n
+		}
+	}
+	segment := strconv.Itoa(max + 1)
+	if width > 0 {
+		if len(segment) > width {
+			e = errors.New(cs.Identifier + ": codes under " + prefix + " exhausted!")
+			return
+		}
+		segment = strings.Repeat(cs.pad, width-len(segment)) + segment
+	}
+	code = prefix + segment
+	return
+}
+
+// likeEscape: s matched literally by LIKE ... ESCAPE '!'.
+func likeEscape(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// AddItem inserts an item under parentid, generating its code if row has none.
+func (cs *CodesetService) AddItem(parentid int64, row RowT) (id int64, code string, e error) {
+	values := RowT{}
+	for k, v := range row {
+		values[k] = v
+	}
+	if code = toString(values["code"]); len(code) == 0 {
+		if code, e = cs.NextCode(parentid); e != nil {
+			return
+		}
+	}
+	if e = cs.ValidateCode(code); e != nil {
+		return
+	}
+	if cs.hierarchical && parentid > 0 {
+		parent, err := cs.Repo.Get(cs.Identifier, parentid)
+		if err != nil {
+			e = err
+			return
+		}
+		if pcode := toString(parent["code"]); !strings.HasPrefix(code, pcode) || cs.level(code) != cs.level(pcode)+1 {
+			e = errors.New(cs.Identifier + ": code " + code + " is not a child code of " + pcode + "!")
+			return
+		}
+	}
+	values["code"] = code
+	if cs.Repo.tables[cs.Identifier].hierarchical {
+		values["parentid"] = parentid
+	}
+	id, e = cs.Repo.Insert(cs.Identifier, values)
+	return
+}
+
+// SetEnabled sets enableflag of the item, disabling an item of a hierarchical codeset disables its descendants too.
+func (cs *CodesetService) SetEnabled(id int64, enabled bool) (affected int64, e error) {
+	flag := 0
+	if enabled {
+		flag = 1
+	}
+	rt := cs.Repo.tables[cs.Identifier]
+	e = cs.Repo.inTx(func(tx *sql.Tx) (err error) {
+		ids := []int64{id}
+		if rt.hierarchical && !enabled {
+			descendants, err := cs.Repo.descendantIDs(tx, rt, id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, descendants...)
+		}
+		for _, i := range ids {
+			var n int64
+			if n, err = cs.Repo.update(tx, rt, i, RowT{"enableflag": flag}); err != nil {
+				return
+			}
+			affected += n
+		}
+		return
+	})
+	return
+}
+
+/*
+RecomputeOccurrences counts for every item the rows referring to it in the tables tio finds by CodesetRelatedTables,
+an id property refers by id and others by code. occurrences is set to {"<table>.<property>": count}, empty when unused.
+*/
+func (cs *CodesetService) RecomputeOccurrences(tio *TableInObject) (updated int64, e error) {
+	rt := cs.Repo.tables[cs.Identifier]
+	items, err := cs.Repo.query(cs.Repo.DB, rt, "SELECT id,code FROM "+cs.Repo.ident(cs.Identifier))
+	if err != nil {
+		e = err
+		return
+	}
+	mapCode := make(map[string]int64)
+	occurrences := make(map[int64]map[string]int64)
+	for _, item := range items {
+		id := toInt64(item["id"])
+		mapCode[toString(item["code"])] = id
+		occurrences[id] = make(map[string]int64)
+	}
+	for _, cp := range tio.CodesetRelatedTables(cs.Identifier) {
+		column := cs.Repo.ident(cp.Propertyname)
+		query := "SELECT " + column + ",COUNT(*) FROM " + cs.Repo.ident(cp.Tablename) + " GROUP BY " + column
+		rs, err := cs.Repo.DB.Query(query)
+		if err != nil {
+			e = errors.New(cp.Tablename + "." + cp.Propertyname + ": " + err.Error())
+			return
+		}
+		for rs.Next() {
+			var ref sql.NullString
+			var n int64
+			if e = rs.Scan(&ref, &n); e != nil {
+				rs.Close()
+				return
+			}
+			id := int64(0)
+			if cp.Propertytype == "id" {
+				id = int64(base.Str2int(ref.String))
+			} else {
+				id = mapCode[ref.String]
+			}
+			if m, ok := occurrences[id]; ok {
+				m[cp.Tablename+"."+cp.Propertyname] += n
+			}
+		}
+		rs.Close()
+	}
+	ids := []int64{}
+	for id := range occurrences {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	e = cs.Repo.inTx(func(tx *sql.Tx) (err error) {
+		for _, id := range ids {
+			txt := ""
+			if len(occurrences[id]) > 0 {
+				bb, _ := json.Marshal(occurrences[id]) //keys sorted
+				txt = string(bb)
+			}
+			if _, err = tx.Exec("UPDATE "+cs.Repo.ident(cs.Identifier)+" SET occurrences="+cs.Repo.placeholder(1)+" WHERE id="+cs.Repo.placeholder(2), txt, id); err != nil {
+				return
+			}
+			updated++
+		}
+		return
+	})
+	return
+}
+
+func toString(v any) (s string) {
+	switch t := v.(type) {
+	case string:
+		s = t
+	case []byte:
+		s = string(t)
+	case nil:
+	default:
+		bb, _ := json.Marshal(t)
+		s = string(bb)
+	}
+	return
+}
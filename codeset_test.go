@@ -0,0 +1,176 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+const codesetDefinition = `{"region":{"type":"codeset","coding_type":"hierarchical","code_structure":"2,2","self_relationship":"hierarchical",
+	"id":{"type":"int"},"parentid":{"type":"int"},"ordinalposition":{"type":"int"},"depth":{"type":"int"},"isleaf":{"type":"int"},
+	"code":{"type":"string","size":4,"pattern":"^[0-9_]+$"},"name":{"type":"string","size":50},
+	"enableflag":{"type":"int","default":"1"},"occurrences":{"type":"text"},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"}]},
+"color":{"type":"codeset","code_structure":"3",
+	"id":{"type":"int"},"code":{"type":"string","size":10},"name":{"type":"string","size":50},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"}]},
+"shop":{"type":"object",
+	"id":{"type":"int"},"region_id":{"type":"int","options":"region"},"region_code":{"type":"string","codeset":"region"},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"}]}}`
+
+func newTestCodeset(t *testing.T, identifier string) (cs *CodesetService) {
+	t.Helper()
+	db := openTestDB(t)
+	ins := NewInstaller(db, base.SQLite, "tester")
+	for _, id := range []string{"region", "color", "shop"} {
+		if _, e := ins.Install(codesetDefinition, id); e != nil {
+			t.Fatalf("install %s: %v", id, e)
+		}
+	}
+	cs, e := NewCodesetService(db, base.SQLite, codesetDefinition, identifier)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return
+}
+
+func TestValidateCode(t *testing.T) {
+	cs := newTestCodeset(t, "region")
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"01", true},
+		{"0102", true},
+		{"1_", true},
+		{"", false},
+		{"0a", false},    //pattern
+		{"01020", false}, //size
+		{"010", false},   //code_structure
+	}
+	for _, tt := range tests {
+		if e := cs.ValidateCode(tt.code); (e == nil) != tt.ok {
+			t.Errorf("ValidateCode(%q) = %v, ok %v", tt.code, e, tt.ok)
+		}
+	}
+	if _, e := NewCodesetService(cs.Repo.DB, base.SQLite, codesetDefinition, "shop"); e == nil {
+		t.Error("NewCodesetService(shop): no error for an object")
+	}
+}
+
+func TestNextCodeAddItem(t *testing.T) {
+	cs := newTestCodeset(t, "region")
+	id1, code, e := cs.AddItem(0, RowT{"name": "north"})
+	if e != nil || code != "01" {
+		t.Fatalf("AddItem = %q, %v, want 01", code, e)
+	}
+	if _, code, e = cs.AddItem(0, RowT{"name": "south"}); e != nil || code != "02" {
+		t.Fatalf("AddItem = %q, %v, want 02", code, e)
+	}
+	if _, code, e = cs.AddItem(id1, RowT{"name": "north east"}); e != nil || code != "0101" {
+		t.Fatalf("AddItem under 01 = %q, %v, want 0101", code, e)
+	}
+	if _, _, e = cs.AddItem(id1, RowT{"code": "0107"}); e != nil {
+		t.Fatal(e)
+	}
+	if code, e = cs.NextCode(id1); e != nil || code != "0108" {
+		t.Errorf("NextCode under 01 = %q, %v, want 0108", code, e)
+	}
+	if _, _, e = cs.AddItem(id1, RowT{"code": "0201"}); e == nil {
+		t.Error("AddItem 0201 under 01: no error")
+	}
+	if _, _, e = cs.AddItem(id1, RowT{"code": "01"}); e == nil {
+		t.Error("AddItem 01 under 01: no error")
+	}
+
+	//the prefix is matched literally: "1_" does not take 1001 as its child
+	id9, _, e := cs.AddItem(0, RowT{"code": "10"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, _, e = cs.AddItem(id9, RowT{"code": "1009"}); e != nil {
+		t.Fatal(e)
+	}
+	id_, _, e := cs.AddItem(0, RowT{"code": "1_"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if code, e = cs.NextCode(id_); e != nil || code != "1_01" {
+		t.Errorf("NextCode under 1_ = %q, %v, want 1_01", code, e)
+	}
+	if code, e = cs.NextCode(0); e != nil || code != "11" {
+		t.Errorf("NextCode = %q, %v, want 11", code, e)
+	}
+
+	leaf, err := cs.Repo.List("region", ListOptionT{Where: RowT{"code": "0101"}})
+	if err != nil || len(leaf) != 1 {
+		t.Fatal(err)
+	}
+	if _, e = cs.NextCode(toInt64(leaf[0]["id"])); e == nil {
+		t.Error("NextCode below the last level: no error")
+	}
+
+	color := newTestCodeset(t, "color")
+	if code, e = color.NextCode(0); e != nil || code != "001" {
+		t.Errorf("color NextCode = %q, %v, want 001", code, e)
+	}
+	for _, c := range []string{"007", "abc"} {
+		if _, _, e = color.AddItem(0, RowT{"code": c}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if code, e = color.NextCode(0); e != nil || code != "008" {
+		t.Errorf("color NextCode = %q, %v, want 008", code, e)
+	}
+}
+
+func TestRecomputeOccurrences(t *testing.T) {
+	cs := newTestCodeset(t, "region")
+	id1, _, e := cs.AddItem(0, RowT{"name": "north"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	id2, _, e := cs.AddItem(0, RowT{"name": "south"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	id3, _, e := cs.AddItem(0, RowT{"name": "west"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, row := range []RowT{
+		{"region_id": id1, "region_code": "01"},
+		{"region_id": id1, "region_code": "02"},
+		{"region_id": id2, "region_code": "02"},
+	} {
+		if _, e = cs.Repo.DB.Exec("INSERT INTO shop(region_id,region_code) VALUES(?,?)", row["region_id"], row["region_code"]); e != nil {
+			t.Fatal(e)
+		}
+	}
+	var tio TableInObject
+	tio.Identifier = "shop"
+	if e = tio.Parse(`{"type":"object","region_id":{"type":"int","options":"region"},"region_code":{"type":"string","codeset":"region"}}`); e != nil {
+		t.Fatal(e)
+	}
+	updated, e := cs.RecomputeOccurrences(&tio)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if updated != 3 {
+		t.Errorf("updated = %d, want 3", updated)
+	}
+	want := map[int64]string{
+		id1: `{"shop.region_code":1,"shop.region_id":2}`,
+		id2: `{"shop.region_code":2,"shop.region_id":1}`,
+		id3: "",
+	}
+	for id, occurrences := range want {
+		row, err := cs.Repo.Get("region", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := toString(row["occurrences"]); got != occurrences {
+			t.Errorf("occurrences of %d = %q, want %q", id, got, occurrences)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package object
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// ImportResultT counts what an import did with the items.
+type ImportResultT struct {
+	Inserted int
+	Updated  int
+}
+
+/*
+codesetColumns: the columns of an exported item, parent_code stands for parentid.
+A multi-language codeset has name.<language_id> and description.<language_id> per accepted language, like CodesetGridTitle.
+*/
+func (cs *CodesetService) codesetColumns() (columns []string, multiple bool) {
+	columns = []string{"code"}
+	if cs.Repo.tables[cs.Identifier].hierarchical {
+		columns = append(columns, "parent_code")
+	}
+	multiple = cs.multiLanguage()
+	for _, key := range []string{"name", "description"} {
+		if multiple {
+			for _, l := range base.AcceptLanguages(1) {
+				columns = append(columns, key+"."+l.Language_id)
+			}
+		} else {
+			columns = append(columns, key)
+		}
+	}
+	columns = append(columns, "enableflag", "ordinalposition")
+	return
+}
+
+// multiLanguage: as CodesetGridTitle, a "multiple" language codeset with more than one accepted language.
+func (cs *CodesetService) multiLanguage() bool {
+	_, _, adaptive, err := cs.Repo.languageTable(cs.Identifier)
+	return err == nil && len(adaptive) > 0 && cs.Repo.tables[cs.Identifier].o.Get("language").String() == "multiple" && len(base.AcceptLanguages(1)) > 1
+}
+
+// exportRecords: the items, parents before their children.
+func (cs *CodesetService) exportRecords() (columns []string, records []map[string]string, e error) {
+	columns, multiple := cs.codesetColumns()
+	rt, lt := cs.Repo.tables[cs.Identifier], cs.Repo.tables[cs.Identifier+"_languages"]
+	orderby := []string{"ordinalposition", "id"}
+	if rt.hierarchical {
+		orderby = []string{"depth", "parentid", "ordinalposition", "id"}
+	}
+	rows, err := cs.Repo.List(cs.Identifier, ListOptionT{OrderBy: orderby})
+	if err != nil {
+		e = err
+		return
+	}
+	mapCode := make(map[int64]string)
+	for _, row := range rows {
+		mapCode[toInt64(row["id"])] = toString(row["code"])
+	}
+	for _, row := range rows {
+		record := map[string]string{"code": toString(row["code"])}
+		if rt.hierarchical {
+			record["parent_code"] = mapCode[toInt64(row["parentid"])]
+		}
+		record["enableflag"] = strconv.FormatInt(toInt64(row["enableflag"]), 10)
+		record["ordinalposition"] = strconv.FormatInt(toInt64(row["ordinalposition"]), 10)
+		if multiple { //translations only, the row itself stands for the base language
+			mapL, err := cs.Repo.translations(cs.Repo.DB, rt, lt, toInt64(row["id"]))
+			if err != nil {
+				e = err
+				return
+			}
+			mapL[base.BaseLanguage_id()] = row
+			for _, l := range base.AcceptLanguages(1) {
+				record["name."+l.Language_id] = toString(mapL[l.Language_id]["name"])
+				record["description."+l.Language_id] = toString(mapL[l.Language_id]["description"])
+			}
+		} else {
+			record["name"] = toString(row["name"])
+			record["description"] = toString(row["description"])
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+// ExportCSV writes the items as CSV with a header line.
+func (cs *CodesetService) ExportCSV(w io.Writer) (e error) {
+	columns, records, err := cs.exportRecords()
+	if err != nil {
+		e = err
+		return
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(columns)
+	for _, record := range records {
+		line := []string{}
+		for _, c := range columns {
+			line = append(line, record[c])
+		}
+		cw.Write(line)
+	}
+	cw.Flush()
+	e = cw.Error()
+	return
+}
+
+// ExportJSON writes the items as a JSON array of objects keyed like the CSV columns.
+func (cs *CodesetService) ExportJSON(w io.Writer) (e error) {
+	_, records, err := cs.exportRecords()
+	if err != nil {
+		e = err
+		return
+	}
+	if records == nil {
+		records = []map[string]string{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	e = enc.Encode(records)
+	return
+}
+
+// ImportCSV reads items as ExportCSV writes them, the header line names the columns.
+func (cs *CodesetService) ImportCSV(r io.Reader, merge bool) (result ImportResultT, e error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	lines, err := cr.ReadAll()
+	if err != nil {
+		e = err
+		return
+	}
+	records := []map[string]string{}
+	for i := 1; i < len(lines); i++ {
+		record := make(map[string]string)
+		for j, c := range lines[0] {
+			if j < len(lines[i]) {
+				record[strings.TrimSpace(c)] = lines[i][j]
+			}
+		}
+		records = append(records, record)
+	}
+	result, e = cs.importRecords(records, merge)
+	return
+}
+
+// ImportJSON reads items as ExportJSON writes them.
+func (cs *CodesetService) ImportJSON(r io.Reader, merge bool) (result ImportResultT, e error) {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		e = err
+		return
+	}
+	if !gjson.ValidBytes(bb) || !gjson.ParseBytes(bb).IsArray() {
+		e = errors.New(cs.Identifier + " import: JSON array expected!")
+		return
+	}
+	records := []map[string]string{}
+	for _, item := range gjson.ParseBytes(bb).Array() {
+		record := make(map[string]string)
+		item.ForEach(func(k, v gjson.Result) bool {
+			record[k.String()] = v.String()
+			return true
+		})
+		records = append(records, record)
+	}
+	result, e = cs.importRecords(records, merge)
+	return
+}
+
+/*
+importRecords inserts the items in one transaction, a parent must exist or come earlier.
+An item whose code exists already is an error, unless merge is set and then the item is updated, moved to its parent included.
+Empty cells leave a value as it is, an empty parent_code is a first level item.
+*/
+func (cs *CodesetService) importRecords(records []map[string]string, merge bool) (result ImportResultT, e error) {
+	repo := cs.Repo
+	rt := repo.tables[cs.Identifier]
+	lt := repo.tables[cs.Identifier+"_languages"]
+	multiple := cs.multiLanguage()
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		codeID := func(code string) (id int64, err error) {
+			err = tx.QueryRow("SELECT id FROM "+repo.ident(cs.Identifier)+" WHERE code="+repo.placeholder(1), code).Scan(&id)
+			return
+		}
+		for i, record := range records {
+			line := cs.Identifier + " import item " + strconv.Itoa(i+1) + ": "
+			code := strings.TrimSpace(record["code"])
+			if err = cs.ValidateCode(code); err != nil {
+				err = errors.New(line + err.Error())
+				return
+			}
+			values := RowT{}
+			translations := make(map[string]RowT)
+			for k, v := range record {
+				if len(v) == 0 {
+					continue
+				}
+				switch k {
+				case "name", "description":
+					values[k] = v
+				case "enableflag", "ordinalposition":
+					values[k] = base.Str2int(v)
+				default:
+					if key, language_id, ok := strings.Cut(k, "."); ok && (key == "name" || key == "description") && multiple {
+						if translations[language_id] == nil {
+							translations[language_id] = RowT{}
+						}
+						translations[language_id][key] = v
+					}
+				}
+			}
+			if parent_code, ok := record["parent_code"]; ok && rt.hierarchical {
+				parentid := int64(0)
+				if parent_code = strings.TrimSpace(parent_code); len(parent_code) > 0 {
+					if parentid, err = codeID(parent_code); err != nil {
+						err = errors.New(line + "parent " + parent_code + " not exists!")
+						return
+					}
+					if cs.hierarchical && (!strings.HasPrefix(code, parent_code) || cs.level(code) != cs.level(parent_code)+1) {
+						err = errors.New(line + "code " + code + " is not a child code of " + parent_code + "!")
+						return
+					}
+				}
+				values["parentid"] = parentid
+			}
+			if base_tr, ok := translations[base.BaseLanguage_id()]; ok { //the row itself holds the base language
+				for k, v := range base_tr {
+					values[k] = v
+				}
+			}
+			id, err2 := codeID(code)
+			switch {
+			case err2 == sql.ErrNoRows:
+				values["code"] = code
+				if id, err = repo.insert(tx, rt, values); err != nil {
+					err = errors.New(line + err.Error())
+					return
+				}
+				result.Inserted++
+			case err2 != nil:
+				err = err2
+				return
+			case merge:
+				if _, err = repo.update(tx, rt, id, values); err != nil {
+					err = errors.New(line + err.Error())
+					return
+				}
+				result.Updated++
+			default:
+				err = errors.New(line + "code " + code + " exists!")
+				return
+			}
+			for _, language_id := range sortedKeys(translations) {
+				if err = repo.setTranslation(tx, rt, lt, id, language_id, translations[language_id]); err != nil {
+					err = errors.New(line + err.Error())
+					return
+				}
+			}
+		}
+		return
+	})
+	return
+}
@@ -0,0 +1,155 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+const codesetIODefinition = `{"area":{"type":"codeset","language":"multiple","coding_type":"hierarchical","code_structure":"2,2","self_relationship":"hierarchical",
+	"id":{"type":"int"},"code":{"type":"string","size":4,"pattern":"^[0-9]+$"}}}`
+
+const codesetIOCSV = `code,parent_code,name.1,name.2,description.1,description.2,enableflag,ordinalposition
+01,,North,Bei,north side,,1,1
+02,,South,Nan,,south zh,0,2
+0101,01,"Hill, high",Shan,,,1,1
+`
+
+// newTestCodesetIO: the multi-language area codeset, extended with name, description and enableflag and installed.
+func newTestCodesetIO(t *testing.T) (cs *CodesetService) {
+	t.Helper()
+	extended, _, e := DefinitionExtend([]byte(codesetIODefinition), "area", "", "", "", true)
+	if e != nil {
+		t.Fatal(e)
+	}
+	db := openTestDB(t)
+	if _, e = NewInstaller(db, base.SQLite, "tester").Install(extended, "area"); e != nil {
+		t.Fatal(e)
+	}
+	if cs, e = NewCodesetService(db, base.SQLite, extended, "area"); e != nil {
+		t.Fatal(e)
+	}
+	return
+}
+
+func TestCodesetCSVRoundTrip(t *testing.T) {
+	cs := newTestCodesetIO(t)
+	result, e := cs.ImportCSV(strings.NewReader(codesetIOCSV), false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if result != (ImportResultT{Inserted: 3}) {
+		t.Errorf("import = %+v, want 3 inserted", result)
+	}
+	rows, e := cs.Repo.List("area", ListOptionT{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	mapID := make(map[string]int64)
+	for _, row := range rows {
+		mapID[toString(row["code"])] = toInt64(row["id"])
+	}
+	hill, e := cs.Repo.GetInLanguage("area", mapID["0101"], "2")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if toInt64(hill["parentid"]) != mapID["01"] || toInt64(hill["depth"]) != 1 || hill["name"] != "Shan" {
+		t.Errorf("0101 = %v, want parent 01, depth 1, name Shan", hill)
+	}
+	if north, e := cs.Repo.Get("area", mapID["01"]); e != nil || north["name"] != "North" || toInt64(north["isleaf"]) != 0 {
+		t.Errorf("01 = %v, %v, want the base language name and no leaf", north, e)
+	}
+
+	var out bytes.Buffer
+	if e = cs.ExportCSV(&out); e != nil {
+		t.Fatal(e)
+	}
+	if out.String() != codesetIOCSV {
+		t.Errorf("ExportCSV =\n%s\nwant\n%s", out.String(), codesetIOCSV)
+	}
+
+	if _, e = cs.ImportCSV(strings.NewReader("code,name.2\n01,Beifang\n"), false); e == nil || !strings.Contains(e.Error(), "code 01 exists!") {
+		t.Errorf("import of an existing code without merge: %v", e)
+	}
+	result, e = cs.ImportCSV(strings.NewReader("code,parent_code,name.2,description.1\n01,,Beifang,\n03,,West,\n"), true)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if result != (ImportResultT{Inserted: 1, Updated: 1}) {
+		t.Errorf("merge = %+v, want 1 inserted, 1 updated", result)
+	}
+	north, e := cs.Repo.GetInLanguage("area", mapID["01"], "2")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if north["name"] != "Beifang" || north["description"] != "north side" {
+		t.Errorf("01 after merge = %v, want zh name Beifang and the description kept", north)
+	}
+	if north, _ = cs.Repo.Get("area", mapID["01"]); north["name"] != "North" {
+		t.Errorf("01 base name after merge = %v, want North", north["name"])
+	}
+
+	for _, tt := range []string{
+		"code,parent_code\n0201,09\n", //missing parent
+		"code,parent_code\n0201,01\n", //not a child code
+		"code\n0a\n",                  //pattern
+	} {
+		if _, e = cs.ImportCSV(strings.NewReader(tt), true); e == nil {
+			t.Errorf("import %q: no error", tt)
+		}
+	}
+	if n, _ := cs.Repo.Count("area", nil); n != 4 {
+		t.Errorf("%d items after failed imports, want 4", n)
+	}
+}
+
+func TestCodesetJSONRoundTrip(t *testing.T) {
+	cs := newTestCodesetIO(t)
+	if _, e := cs.ImportCSV(strings.NewReader(codesetIOCSV), false); e != nil {
+		t.Fatal(e)
+	}
+	var out bytes.Buffer
+	if e := cs.ExportJSON(&out); e != nil {
+		t.Fatal(e)
+	}
+	if want := `"parent_code": "01"`; !strings.Contains(out.String(), want) {
+		t.Errorf("ExportJSON lacks %s:\n%s", want, out.String())
+	}
+
+	cs2 := newTestCodesetIO(t)
+	result, e := cs2.ImportJSON(bytes.NewReader(out.Bytes()), false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if result != (ImportResultT{Inserted: 3}) {
+		t.Errorf("import = %+v, want 3 inserted", result)
+	}
+	var out2 bytes.Buffer
+	if e = cs2.ExportJSON(&out2); e != nil {
+		t.Fatal(e)
+	}
+	if out2.String() != out.String() {
+		t.Errorf("JSON round trip =\n%s\nwant\n%s", out2.String(), out.String())
+	}
+	var csvOut bytes.Buffer
+	if e = cs2.ExportCSV(&csvOut); e != nil || csvOut.String() != codesetIOCSV {
+		t.Errorf("ExportCSV after the JSON import = %v\n%s", e, csvOut.String())
+	}
+
+	result, e = cs2.ImportJSON(strings.NewReader(`[{"code":"02","name.2":"Nanfang","enableflag":"1"}]`), true)
+	if e != nil || result != (ImportResultT{Updated: 1}) {
+		t.Errorf("merge = %+v, %v", result, e)
+	}
+	rows, _ := cs2.Repo.List("area", ListOptionT{Where: RowT{"code": "02"}})
+	if len(rows) != 1 || toInt64(rows[0]["enableflag"]) != 1 {
+		t.Fatalf("02 after merge = %v", rows)
+	}
+	if south, _ := cs2.Repo.GetInLanguage("area", toInt64(rows[0]["id"]), "2"); south["name"] != "Nanfang" || south["description"] != "south zh" {
+		t.Errorf("02 zh after merge = %v", south)
+	}
+	if _, e = cs2.ImportJSON(strings.NewReader(`{"code":"04"}`), false); e == nil {
+		t.Error("import of a JSON object: no error")
+	}
+}
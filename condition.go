@@ -0,0 +1,285 @@
+package object
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+ConditionT is a parsed action condition like "released=0&user_id=session@user_id":
+terms joined by "&", each term is left op right with op one of !=, <=, >=, <, >, =, ∈ (found in that order, as extractProperty does).
+A "&", an operator or a "," inside a 'string' is part of the string.
+An operand is a property ("recommend.ordinalposition" for a joined table), a number, a 'string' or session@name.
+The right side of ∈ is a list: "status_id∈1,2,3" or "status_id∈(1,2,3)".
+*/
+type ConditionT struct {
+	Text  string
+	terms []conditionTermT
+}
+
+type conditionTermT struct {
+	op    string
+	left  conditionOperandT
+	right []conditionOperandT
+}
+
+type conditionOperandT struct {
+	kind  string //property, number, string, session
+	value string
+}
+
+var (
+	conditionOperators = strings.Split("!=,<=,>=,<,>,=,∈", ",")
+	propertyPathRegex  = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*(\\.[a-zA-Z_][a-zA-Z0-9_]*)?$")
+)
+
+// ParseCondition parses an action condition, empty is a condition always true.
+func ParseCondition(txt string) (cond *ConditionT, e error) {
+	cond = &ConditionT{Text: txt}
+	if len(strings.TrimSpace(txt)) == 0 {
+		return
+	}
+	for _, expression := range splitUnquoted(txt, "&") {
+		var term conditionTermT
+		at := -1
+		for _, op := range conditionOperators {
+			if at = unquotedIndex(expression, op); at >= 0 {
+				term.op = op
+				break
+			}
+		}
+		if len(term.op) == 0 {
+			e = errors.New("condition " + expression + ": operator expected")
+			return
+		}
+		if term.left, e = parseConditionOperand(expression[:at]); e != nil {
+			return
+		}
+		right := strings.TrimSpace(expression[at+len(term.op):])
+		if term.op == "∈" {
+			right = strings.TrimSuffix(strings.TrimPrefix(right, "("), ")")
+			for _, r := range splitUnquoted(right, ",") {
+				operand, err := parseConditionOperand(r)
+				if err != nil {
+					e = err
+					return
+				}
+				term.right = append(term.right, operand)
+			}
+		} else {
+			operand, err := parseConditionOperand(right)
+			if err != nil {
+				e = err
+				return
+			}
+			term.right = []conditionOperandT{operand}
+		}
+		cond.terms = append(cond.terms, term)
+	}
+	return
+}
+
+// unquotedIndex: the index of the first sub in s outside a 'string', -1 if there is none.
+func unquotedIndex(s, sub string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' {
+			quoted = !quoted //'' inside a string toggles twice
+		} else if !quoted && strings.HasPrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitUnquoted: strings.Split leaving the sep inside a 'string' alone.
+func splitUnquoted(s, sep string) (ss []string) {
+	for at := unquotedIndex(s, sep); at >= 0; at = unquotedIndex(s, sep) {
+		ss = append(ss, s[:at])
+		s = s[at+len(sep):]
+	}
+	ss = append(ss, s)
+	return
+}
+
+func parseConditionOperand(txt string) (operand conditionOperandT, e error) {
+	txt = strings.TrimSpace(txt)
+	switch {
+	case len(txt) == 0:
+		e = errors.New("condition: operand expected")
+	case strings.HasPrefix(txt, "session@"):
+		name := strings.TrimPrefix(txt, "session@")
+		if !identifierRegex.MatchString(name) {
+			e = errors.New("condition: invalid session variable " + txt)
+		}
+		operand = conditionOperandT{"session", name}
+	case len(txt) >= 2 && strings.HasPrefix(txt, "'") && strings.HasSuffix(txt, "'"):
+		operand = conditionOperandT{"string", strings.ReplaceAll(txt[1:len(txt)-1], "''", "'")}
+	default:
+		if _, err := strconv.ParseFloat(txt, 64); err == nil {
+			operand = conditionOperandT{"number", txt}
+		} else if propertyPathRegex.MatchString(txt) {
+			operand = conditionOperandT{"property", txt}
+		} else {
+			e = errors.New("condition: invalid operand " + txt)
+		}
+	}
+	return
+}
+
+// Properties: the properties the condition refers to, like extractProperty.
+func (cond *ConditionT) Properties() (properties []string) {
+	for _, term := range cond.terms {
+		for _, operand := range append([]conditionOperandT{term.left}, term.right...) {
+			if operand.kind == "property" {
+				addUnique(&properties, []string{operand.value})
+			}
+		}
+	}
+	return
+}
+
+func (operand conditionOperandT) eval(row RowT, session map[string]any) (value string, e error) {
+	switch operand.kind {
+	case "property":
+		v, ok := row[operand.value]
+		if !ok {
+			e = errors.New("condition: " + operand.value + " not in row")
+			return
+		}
+		value = toString(v)
+	case "session":
+		v, ok := session[operand.value]
+		if !ok {
+			e = errors.New("condition: session@" + operand.value + " not set")
+			return
+		}
+		value = toString(v)
+	default:
+		value = operand.value
+	}
+	return
+}
+
+// compareValues: numbers compare as numbers, else as strings.
+func compareValues(a, b string) int {
+	fa, ea := strconv.ParseFloat(a, 64)
+	fb, eb := strconv.ParseFloat(b, 64)
+	if ea == nil && eb == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// Eval tells if row meets the condition, session holds the session@ values (as UserAccessWhere takes them).
+func (cond *ConditionT) Eval(row RowT, session map[string]any) (ok bool, e error) {
+	for _, term := range cond.terms {
+		left, err := term.left.eval(row, session)
+		if err != nil {
+			e = err
+			return
+		}
+		rights := []string{}
+		for _, operand := range term.right {
+			right, err := operand.eval(row, session)
+			if err != nil {
+				e = err
+				return
+			}
+			rights = append(rights, right)
+		}
+		met := false
+		switch term.op {
+		case "∈":
+			for _, right := range rights {
+				if compareValues(left, right) == 0 {
+					met = true
+					break
+				}
+			}
+		case "=":
+			met = compareValues(left, rights[0]) == 0
+		case "!=":
+			met = compareValues(left, rights[0]) != 0
+		case "<":
+			met = compareValues(left, rights[0]) < 0
+		case "<=":
+			met = compareValues(left, rights[0]) <= 0
+		case ">":
+			met = compareValues(left, rights[0]) > 0
+		case ">=":
+			met = compareValues(left, rights[0]) >= 0
+		}
+		if !met {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+/*
+Where translates the condition to a WHERE fragment with placeholders of db_type,
+properties without a table are qualified by alias if any, session@ values are arguments.
+*/
+func (cond *ConditionT) Where(db_type int, alias string, session map[string]any) (where string, args []any, e error) {
+	sa := sqlArgsT{db_type: db_type}
+	ww := []string{}
+	for _, term := range cond.terms {
+		ss := []string{}
+		for _, operand := range append([]conditionOperandT{term.left}, term.right...) {
+			switch operand.kind {
+			case "property":
+				pp := strings.Split(operand.value, ".")
+				for i := range pp {
+					pp[i] = sqlIdentifier(pp[i], db_type)
+				}
+				if len(pp) == 1 && len(alias) > 0 {
+					pp = append([]string{alias}, pp...)
+				}
+				ss = append(ss, strings.Join(pp, "."))
+			case "number":
+				ss = append(ss, operand.value)
+			case "string":
+				ss = append(ss, sa.bind(operand.value))
+			case "session":
+				v, ok := session[operand.value]
+				if !ok {
+					e = errors.New("condition: session@" + operand.value + " not set")
+					return
+				}
+				ss = append(ss, sa.bind(v))
+			}
+		}
+		if term.op == "∈" {
+			ww = append(ww, ss[0]+" IN ("+strings.Join(ss[1:], ",")+")")
+		} else {
+			ww = append(ww, ss[0]+term.op+ss[1])
+		}
+	}
+	where = strings.Join(ww, " AND ")
+	args = sa.args
+	return
+}
+
+// EnabledActions: the actions of col whose condition row meets, an invalid condition disables its action.
+func EnabledActions(col ColumnT, row RowT, session map[string]any) (actions []string) {
+	for _, action := range col.Actions {
+		cond, err := ParseCondition(action.Condition)
+		if err != nil {
+			continue
+		}
+		if ok, _ := cond.Eval(row, session); ok {
+			actions = append(actions, action.Action)
+		}
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+func TestConditionEval(t *testing.T) {
+	row := RowT{"released": int64(0), "user_id": int64(7), "status_id": "2", "title": "it's", "score": 9.5, "tag": "<b>", "name": "a&b"}
+	session := map[string]any{"user_id": 7, "name": "it's"}
+	tests := []struct {
+		cond string
+		ok   bool
+	}{
+		{"", true},
+		{"released=0", true},
+		{"released=1", false},
+		{"released!=1&user_id=session@user_id", true},
+		{"user_id=session@user_id & released=1", false},
+		{"status_id∈1,2,3", true},
+		{"status_id∈(3,4)", false},
+		{"score>9", true},
+		{"score>=9.5", true},
+		{"score<10", true},
+		{"score<=9", false},
+		{"title='it''s'", true},
+		{"title=session@name", true},
+		{"title<'z'", true},
+		{"tag='<b>'", true},
+		{"tag!='a=b'", true},
+		{"name='a&b'", true},
+		{"name='a&b'&released=0", true},
+		{"name∈'x,y','a&b'", true},
+		{"name∈('x,y')", false},
+	}
+	for _, tt := range tests {
+		cond, e := ParseCondition(tt.cond)
+		if e != nil {
+			t.Errorf("ParseCondition(%q): %v", tt.cond, e)
+			continue
+		}
+		if ok, e := cond.Eval(row, session); e != nil || ok != tt.ok {
+			t.Errorf("Eval(%q) = %v %v, want %v", tt.cond, ok, e, tt.ok)
+		}
+	}
+	for _, txt := range []string{"released=session@missing", "missing=1"} {
+		cond, e := ParseCondition(txt)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, e = cond.Eval(row, session); e == nil {
+			t.Errorf("Eval(%q): no error", txt)
+		}
+	}
+	for _, txt := range []string{"released", "released=", "a b=1", "a=session@x-y", "a=1;DROP", "a='x", "'a=b'"} {
+		if _, e := ParseCondition(txt); e == nil {
+			t.Errorf("ParseCondition(%q): no error", txt)
+		}
+	}
+}
+
+func TestConditionWhere(t *testing.T) {
+	session := map[string]any{"user_id": 7}
+	tests := []struct {
+		cond  string
+		where string //MySQL, alias o
+		pg    string //PostgreSQL, no alias
+		args  []any
+	}{
+		{"", "", "", nil},
+		{"released=0&user_id=session@user_id", "o.`released`=0 AND o.`user_id`=?", `"released"=0 AND "user_id"=$1`, []any{7}},
+		{"status_id∈(1,'x')", "o.`status_id` IN (1,?)", `"status_id" IN (1,$1)`, []any{"x"}},
+		{"title='<b>'&name='a&b'", "o.`title`=? AND o.`name`=?", `"title"=$1 AND "name"=$2`, []any{"<b>", "a&b"}},
+		{"recommend.ordinalposition>1", "`recommend`.`ordinalposition`>1", `"recommend"."ordinalposition">1`, nil},
+	}
+	for _, tt := range tests {
+		cond, e := ParseCondition(tt.cond)
+		if e != nil {
+			t.Fatal(e)
+		}
+		where, args, e := cond.Where(base.MySQL, "o", session)
+		if e != nil || where != tt.where || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q MySQL = %q %v %v, want %q %v", tt.cond, where, args, e, tt.where, tt.args)
+		}
+		where, args, e = cond.Where(PostgreSQL, "", session)
+		if e != nil || where != tt.pg || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%q PostgreSQL = %q %v %v, want %q %v", tt.cond, where, args, e, tt.pg, tt.args)
+		}
+	}
+	cond, _ := ParseCondition("user_id=session@missing")
+	if _, _, e := cond.Where(base.MySQL, "", session); e == nil {
+		t.Error("session@missing: no error")
+	}
+}
+
+func TestEnabledActions(t *testing.T) {
+	col := ColumnT{Actions: []actionT{
+		{Action: "remove", Condition: "released=0"},
+		{Action: "release", Condition: "released=0&user_id=session@user_id"},
+		{Action: "revoke", Condition: "released=1"},
+		{Action: "view"},
+		{Action: "broken", Condition: "released"},
+	}}
+	got := EnabledActions(col, RowT{"released": 0, "user_id": 7}, map[string]any{"user_id": 7})
+	if want := []string{"remove", "release", "view"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnabledActions = %q, want %q", got, want)
+	}
+	got = EnabledActions(col, RowT{"released": 0, "user_id": 8}, map[string]any{"user_id": 7})
+	if want := []string{"remove", "view"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnabledActions = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,394 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+ObjectDefinition is the typed tree of an object (or codeset) in a .object definition:
+simple keys of the object, its properties, its sub-objects and its indexes.
+Keys not known here are kept in Attributes, the source key order is kept for serializing.
+*/
+type ObjectDefinition struct {
+	Identifier       string
+	Roadmap          []string //identifiers from the top object, table name is Roadmap joined by "_"
+	Type             string   //object, object_extension, object_relation, codeset
+	Caption          string
+	Comment          string
+	Language         string //single, multiple
+	SelfRelationship string //hierarchical
+	Extension        string //object_extension: language
+	Relation         string //object_relation: related object identifier
+	Attributes       map[string]string
+	Properties       []*PropertyDefinition
+	Objects          []*ObjectDefinition
+	Indexes          []IndexDefinition
+	keys             []string
+	raw              map[string]string
+}
+
+type PropertyDefinition struct {
+	Name             string
+	Type             string //int, long, float, decimal, time, string, password, ipv4, ipv6, dotids, text, blob
+	Size             string
+	Capacity         string //text/blob: M(medium), L(long)
+	DecimalPlaces    string
+	Default          string
+	Options          string //codeset or entity identifier
+	Codeset          string
+	Index            string //single, major, auxiliary
+	Pattern          string
+	Caption          string
+	Comment          string
+	LanguageAdaptive bool
+	Attributes       map[string]string
+	keys             []string
+	raw              map[string]string
+}
+
+type IndexDefinition struct {
+	Name       string
+	Properties string //"parentid,ordinalposition" "time_updated desc"
+	Type       string //primary, single, composite, unique, fulltext, major, auxiliary
+	Attributes map[string]string
+	keys       []string
+	raw        map[string]string
+}
+
+// PropertyNames: index properties without collation.
+func (idx IndexDefinition) PropertyNames() (names []string) {
+	for _, p := range strings.Split(idx.Properties, ",") {
+		p = strings.ReplaceAll(base.TrimBLANK(p), "`", "")
+		if i := strings.Index(p, " "); i > 0 {
+			p = p[:i]
+		}
+		if len(p) > 0 {
+			names = append(names, p)
+		}
+	}
+	return
+}
+
+func (od *ObjectDefinition) fields() map[string]*string {
+	return map[string]*string{"type": &od.Type, "caption": &od.Caption, "comment": &od.Comment, "language": &od.Language,
+		"self_relationship": &od.SelfRelationship, "extension": &od.Extension, "relation": &od.Relation}
+}
+
+func (idx *IndexDefinition) fields() map[string]*string {
+	return map[string]*string{"name": &idx.Name, "properties": &idx.Properties, "type": &idx.Type}
+}
+
+func (pd *PropertyDefinition) fields() map[string]*string {
+	return map[string]*string{"type": &pd.Type, "size": &pd.Size, "capacity": &pd.Capacity, "decimal_places": &pd.DecimalPlaces,
+		"default": &pd.Default, "options": &pd.Options, "codeset": &pd.Codeset, "index": &pd.Index, "pattern": &pd.Pattern,
+		"caption": &pd.Caption, "comment": &pd.Comment}
+}
+
+func (od *ObjectDefinition) Tablename() string {
+	return strings.Join(od.Roadmap, "_")
+}
+
+func (od *ObjectDefinition) Property(name string) *PropertyDefinition {
+	for _, pd := range od.Properties {
+		if pd.Name == name {
+			return pd
+		}
+	}
+	return nil
+}
+
+func (od *ObjectDefinition) Object(name string) *ObjectDefinition {
+	for _, sub := range od.Objects {
+		if sub.Identifier == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// LoadDefinition reads dirRes/res/identifier.object, same as Extend.
+func LoadDefinition(dirRes, identifier string) (od *ObjectDefinition, e error) {
+	fname := filepath.Join(dirRes, "res", identifier+".object")
+	if base.IsExists(fname) {
+		bb, err := ioutil.ReadFile(fname)
+		if err == nil {
+			od, e = ParseDefinition(bb, identifier)
+		} else {
+			e = err
+		}
+	} else {
+		e = errors.New("error object identifier")
+	}
+	return
+}
+
+func ParseDefinition(jsontxt []byte, identifier string) (od *ObjectDefinition, e error) {
+	if gjson.ValidBytes(jsontxt) {
+		od, e = ParseDefinitionResult(gjson.GetBytes(jsontxt, identifier), identifier)
+	} else {
+		e = errors.New("definition error!")
+	}
+	return
+}
+
+// ParseDefinitionResult rejects an invalid access rule in a "limit" of the object or of a sub-object.
+func ParseDefinitionResult(o gjson.Result, identifier string) (od *ObjectDefinition, e error) {
+	if od, e = parseDefinitionResult(o, identifier); e == nil {
+		if e = checkAccessLimits(o, []string{identifier}); e != nil {
+			od = nil
+		}
+	}
+	return
+}
+
+func parseDefinitionResult(o gjson.Result, identifier string) (od *ObjectDefinition, e error) {
+	o_type := o.Get("type").String()
+	if o.IsObject() && (strings.HasPrefix(o_type, "object") || o_type == "codeset") {
+		od = parseObject(o, []string{identifier})
+	} else {
+		e = errors.New(identifier + " syntax error!")
+	}
+	return
+}
+
+func parseObject(o gjson.Result, roadmap []string) (od *ObjectDefinition) {
+	od = &ObjectDefinition{Identifier: roadmap[len(roadmap)-1], Roadmap: roadmap}
+	od.Attributes = make(map[string]string)
+	od.raw = make(map[string]string)
+	fields := od.fields()
+	o.ForEach(func(k, v gjson.Result) bool {
+		key := k.String()
+		od.keys = append(od.keys, key)
+		if key == "indexes" && v.IsArray() {
+			for _, vv := range v.Array() {
+				od.Indexes = append(od.Indexes, parseIndex(vv))
+			}
+		} else if v.IsObject() {
+			if strings.HasPrefix(v.Get("type").String(), "object") {
+				od.Objects = append(od.Objects, parseObject(v, append(append([]string{}, roadmap...), key)))
+			} else {
+				od.Properties = append(od.Properties, parseProperty(key, v))
+			}
+		} else {
+			od.raw[key] = v.Raw
+			if f, ok := fields[key]; ok {
+				*f = v.String()
+			} else {
+				od.Attributes[key] = v.String()
+			}
+		}
+		return true
+	})
+	return
+}
+
+func parseProperty(name string, v gjson.Result) (pd *PropertyDefinition) {
+	pd = &PropertyDefinition{Name: name}
+	pd.Attributes = make(map[string]string)
+	pd.raw = make(map[string]string)
+	fields := pd.fields()
+	v.ForEach(func(k, vv gjson.Result) bool {
+		key := k.String()
+		pd.keys = append(pd.keys, key)
+		pd.raw[key] = vv.Raw
+		if f, ok := fields[key]; ok {
+			*f = vv.String()
+		} else if key == "language_adaptive" {
+			pd.LanguageAdaptive = vv.Bool()
+		} else {
+			pd.Attributes[key] = vv.String()
+		}
+		return true
+	})
+	return
+}
+
+func parseIndex(v gjson.Result) (idx IndexDefinition) {
+	idx.Attributes = make(map[string]string)
+	idx.raw = make(map[string]string)
+	fields := idx.fields()
+	v.ForEach(func(k, vv gjson.Result) bool {
+		key := k.String()
+		idx.keys = append(idx.keys, key)
+		idx.raw[key] = vv.Raw
+		if f, ok := fields[key]; ok {
+			*f = vv.String()
+		} else {
+			idx.Attributes[key] = vv.String()
+		}
+		return true
+	})
+	return
+}
+
+// jsonQuote: s as a json string, unlike quote (Go escapes) always valid json.
+func jsonQuote(s string) (txt string) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	txt = strings.TrimSuffix(buf.String(), "\n")
+	return
+}
+
+// rawValue: the source raw json while the value is unchanged, else the value as a json string.
+func rawValue(raw map[string]string, key, value string) (txt string) {
+	r, ok := raw[key]
+	if ok && gjson.Parse(r).String() == value {
+		txt = r
+	} else if ok && gjson.Parse(r).Type == gjson.Number && base.IsDigital(value) { //keep number as number
+		txt = value
+	} else {
+		txt = jsonQuote(value)
+	}
+	return
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// orderedKeys: source keys first, then keys set after loading in the given order.
+func orderedKeys(keys []string, present func(key string) bool, more []string) (kk []string) {
+	for _, key := range keys {
+		if present(key) {
+			kk = append(kk, key)
+		}
+	}
+	for _, key := range more {
+		exists, _ := base.In_array(key, kk)
+		if !exists && present(key) {
+			kk = append(kk, key)
+		}
+	}
+	return
+}
+
+func (pd *PropertyDefinition) MarshalJSON() (bb []byte, e error) {
+	fields := pd.fields()
+	present := func(key string) bool {
+		if f, ok := fields[key]; ok {
+			_, was := pd.raw[key]
+			return len(*f) > 0 || (was && gjson.Parse(pd.raw[key]).String() == *f)
+		} else if key == "language_adaptive" {
+			_, was := pd.raw[key]
+			return pd.LanguageAdaptive || (was && !gjson.Parse(pd.raw[key]).Bool())
+		}
+		_, ok := pd.Attributes[key]
+		return ok
+	}
+	more := []string{"type", "size", "options", "codeset", "capacity", "decimal_places", "default", "comment", "caption", "pattern", "index", "language_adaptive"}
+	more = append(more, sortedKeys(pd.Attributes)...)
+	mm := []string{}
+	for _, key := range orderedKeys(pd.keys, present, more) {
+		txt := ""
+		if f, ok := fields[key]; ok {
+			txt = rawValue(pd.raw, key, *f)
+		} else if key == "language_adaptive" {
+			txt = rawValue(pd.raw, key, strconv.FormatBool(pd.LanguageAdaptive))
+			if txt == jsonQuote("true") || txt == jsonQuote("false") {
+				txt = strconv.FormatBool(pd.LanguageAdaptive)
+			}
+		} else {
+			txt = rawValue(pd.raw, key, pd.Attributes[key])
+		}
+		mm = append(mm, jsonQuote(key)+": "+txt)
+	}
+	bb = []byte("{" + strings.Join(mm, ",") + "}")
+	return
+}
+
+func (idx *IndexDefinition) MarshalJSON() (bb []byte, e error) {
+	fields := idx.fields()
+	present := func(key string) bool {
+		if f, ok := fields[key]; ok {
+			return len(*f) > 0 || key != "type"
+		}
+		_, ok := idx.Attributes[key]
+		return ok
+	}
+	more := append([]string{"name", "properties", "type"}, sortedKeys(idx.Attributes)...)
+	mm := []string{}
+	for _, key := range orderedKeys(idx.keys, present, more) {
+		txt := ""
+		if f, ok := fields[key]; ok {
+			txt = rawValue(idx.raw, key, *f)
+		} else {
+			txt = rawValue(idx.raw, key, idx.Attributes[key])
+		}
+		mm = append(mm, jsonQuote(key)+": "+txt)
+	}
+	bb = []byte("{" + strings.Join(mm, ",") + "}")
+	return
+}
+
+func (od *ObjectDefinition) MarshalJSON() (bb []byte, e error) {
+	fields := od.fields()
+	present := func(key string) bool {
+		if f, ok := fields[key]; ok {
+			_, was := od.raw[key]
+			return len(*f) > 0 || (was && gjson.Parse(od.raw[key]).String() == *f)
+		} else if key == "indexes" {
+			return len(od.Indexes) > 0
+		} else if od.Property(key) != nil || od.Object(key) != nil {
+			return true
+		}
+		_, ok := od.Attributes[key]
+		return ok
+	}
+	more := []string{"type", "caption", "comment", "language", "self_relationship", "extension", "relation"}
+	more = append(more, sortedKeys(od.Attributes)...)
+	for _, pd := range od.Properties {
+		more = append(more, pd.Name)
+	}
+	for _, sub := range od.Objects {
+		more = append(more, sub.Identifier)
+	}
+	more = append(more, "indexes")
+	mm := []string{}
+	for _, key := range orderedKeys(od.keys, present, more) {
+		txt := ""
+		if f, ok := fields[key]; ok {
+			txt = rawValue(od.raw, key, *f)
+		} else if key == "indexes" {
+			ii := []string{}
+			for i := range od.Indexes {
+				b, _ := od.Indexes[i].MarshalJSON()
+				ii = append(ii, string(b))
+			}
+			txt = "[" + strings.Join(ii, ",") + "]"
+		} else if pd := od.Property(key); pd != nil {
+			b, _ := pd.MarshalJSON()
+			txt = string(b)
+		} else if sub := od.Object(key); sub != nil {
+			b, _ := sub.MarshalJSON()
+			txt = string(b)
+		} else {
+			txt = rawValue(od.raw, key, od.Attributes[key])
+		}
+		mm = append(mm, jsonQuote(key)+": "+txt)
+	}
+	bb = []byte("{" + strings.Join(mm, ",") + "}")
+	return
+}
+
+// Definition serializes back to the .object layout: {"identifier": {...}}.
+func (od *ObjectDefinition) Definition() (definition string) {
+	bb, _ := od.MarshalJSON()
+	definition = "{" + jsonQuote(od.Identifier) + ": " + string(bb) + "}"
+	return
+}
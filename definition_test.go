@@ -0,0 +1,59 @@
+package object
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const roundtripDefinition = `{"book": {"type": "object","caption": "en:Book;zh:书","comment": "<b>&\u0001","self_relationship": "hierarchical","mark": 3,
+	"id": {"type": "int","index": "single"},
+	"title": {"type": "string","size": 50,"language_adaptive": true,"caption": "en:Title","placeholder": "a \"title\""},
+	"price": {"type": "decimal","size": "10","decimal_places": 2,"default": "0"},
+	"chapter": {"type": "object","id": {"type": "int"},"title": {"type": "string","size": 50},
+		"indexes": [{"name": "pk","type": "primary","properties": "id"}]},
+	"indexes": [{"name": "pk","properties": "id","type": "primary"},{"properties": "title","name": "title","comment": "keep me","order": 1}]}}`
+
+func compactJSON(t *testing.T, txt string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if e := json.Compact(buf, []byte(txt)); e != nil {
+		t.Fatalf("%v: %s", e, txt)
+	}
+	return buf.String()
+}
+
+func TestDefinitionRoundTrip(t *testing.T) {
+	od, e := ParseDefinition([]byte(roundtripDefinition), "book")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got, want := compactJSON(t, od.Definition()), compactJSON(t, roundtripDefinition); got != want {
+		t.Errorf("round trip\n got %s\nwant %s", got, want)
+	}
+	if idx := od.Indexes[1]; idx.Name != "title" || idx.Attributes["comment"] != "keep me" {
+		t.Errorf("index = %+v", idx)
+	}
+
+	od.Caption = "a\x01\"b\"\n<c>"
+	od.Property("title").Attributes["placeholder"] = "\x7f "
+	od.Indexes[1].Type = "unique"
+	od.Indexes = append(od.Indexes, IndexDefinition{Name: "price", Properties: "price"})
+	definition := od.Definition()
+	if !json.Valid([]byte(definition)) {
+		t.Fatalf("invalid json: %s", definition)
+	}
+	od2, e := ParseDefinition([]byte(definition), "book")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if od2.Caption != od.Caption || od2.Property("title").Attributes["placeholder"] != "\x7f " {
+		t.Errorf("caption %q placeholder %q", od2.Caption, od2.Property("title").Attributes["placeholder"])
+	}
+	if len(od2.Indexes) != 3 || od2.Indexes[1].Type != "unique" || od2.Indexes[1].Attributes["comment"] != "keep me" || od2.Indexes[2].Name != "price" {
+		t.Errorf("indexes = %+v", od2.Indexes)
+	}
+	if got, want := compactJSON(t, od2.Definition()), compactJSON(t, definition); got != want {
+		t.Errorf("second round trip\n got %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package object
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+type diagramColumnT struct {
+	Name string
+	Type string
+	Keys []string //PK, UK, FK
+}
+
+type diagramTableT struct {
+	Name    string
+	Caption string
+	Columns []diagramColumnT
+}
+
+// diagramModel: the tables of an extended definition and the relations between them.
+func diagramModel(definition, identifier string) (tables []diagramTableT, relations []RelationT, e error) {
+	o := gjson.Get(definition, identifier)
+	if !o.Exists() {
+		e = errors.New(identifier + " syntax error!")
+		return
+	}
+	tio := TableInObject{Identifier: identifier}
+	tio.ParseO(&o)
+	relations = tio.Relations
+	foreign := []string{}
+	for _, r := range relations {
+		foreign = append(foreign, r.From+"."+r.Property)
+	}
+	tt := []tableT{}
+	collectTables(o, []string{identifier}, &tt)
+	for _, t := range tt {
+		table := diagramTableT{Name: strings.Join(t.roadmap, "_"), Caption: t.o.Get("caption").String()}
+		keys := make(map[string][]string)
+		for _, v := range t.o.Get("indexes").Array() {
+			key := ""
+			switch v.Get("type").String() {
+			case "primary":
+				key = "PK"
+			case "unique":
+				key = "UK"
+			}
+			if len(key) > 0 {
+				for _, p := range (IndexDefinition{Properties: v.Get("properties").String()}).PropertyNames() {
+					keys[p] = append(keys[p], key)
+				}
+			}
+		}
+		columns, mapV := tableColumns(t.o)
+		for _, name := range columns {
+			col := diagramColumnT{Name: name, Type: mapV[name].Get("type").String(), Keys: keys[name]}
+			if exists, _ := base.In_array(table.Name+"."+name, foreign); exists {
+				col.Keys = append(col.Keys, "FK")
+			}
+			table.Columns = append(table.Columns, col)
+		}
+		tables = append(tables, table)
+	}
+	return
+}
+
+// Definition2DOT draws the tables of an extended definition as a Graphviz digraph.
+func Definition2DOT(definition, identifier string) (dot string, e error) {
+	tables, relations, err := diagramModel(definition, identifier)
+	if err != nil {
+		e = err
+		return
+	}
+	dot = "digraph " + quote(identifier) + " {\n"
+	dot += "\trankdir=LR;\n"
+	dot += "\tnode [shape=plaintext fontname=\"Helvetica\"];\n"
+	names := []string{}
+	for _, table := range tables {
+		names = append(names, table.Name)
+		label := `<table border="0" cellborder="1" cellspacing="0">`
+		label += `<tr><td bgcolor="lightgrey"><b>` + html.EscapeString(table.Name) + `</b></td></tr>`
+		for _, col := range table.Columns {
+			txt := col.Name + " : " + col.Type
+			if len(col.Keys) > 0 {
+				txt += " " + strings.Join(col.Keys, ",")
+			}
+			label += `<tr><td port="` + html.EscapeString(col.Name) + `" align="left">` + html.EscapeString(txt) + `</td></tr>`
+		}
+		label += `</table>`
+		dot += "\t" + quote(table.Name) + " [label=<" + label + ">];\n"
+	}
+	for _, r := range relations {
+		if exists, _ := base.In_array(r.To, names); !exists {
+			names = append(names, r.To)
+			dot += "\t" + quote(r.To) + " [shape=box style=dashed];\n" //codeset or object of another definition
+		}
+	}
+	for _, r := range relations {
+		style := ""
+		switch r.Kind {
+		case "codeset":
+			style = " style=dashed"
+		case "relation", "extension":
+			style = " style=bold"
+		}
+		dot += "\t" + quote(r.From) + ":" + quote(r.Property) + " -> " + quote(r.To) + " [label=" + quote(r.Kind) + style + "];\n"
+	}
+	dot += "}\n"
+	return
+}
+
+// Definition2Mermaid draws the tables of an extended definition as a Mermaid erDiagram.
+func Definition2Mermaid(definition, identifier string) (mermaid string, e error) {
+	tables, relations, err := diagramModel(definition, identifier)
+	if err != nil {
+		e = err
+		return
+	}
+	mermaid = "erDiagram\n"
+	for _, table := range tables {
+		mermaid += "\t" + table.Name + " {\n"
+		for _, col := range table.Columns {
+			mermaid += "\t\t" + col.Type + " " + col.Name
+			if len(col.Keys) > 0 {
+				mermaid += " " + strings.Join(col.Keys, ", ")
+			}
+			mermaid += "\n"
+		}
+		mermaid += "\t}\n"
+	}
+	for _, r := range relations {
+		mermaid += "\t" + r.From + " }o--|| " + r.To + " : " + quote(r.Kind+" "+r.Property) + "\n"
+	}
+	return
+}
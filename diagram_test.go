@@ -0,0 +1,84 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+const diagramDefinition = `{"book":{"type":"object","caption":"en:Book",
+	"id":{"type":"int"},"title":{"type":"string"},"isbn":{"type":"string"},"author_id":{"type":"int","options":"author"},
+	"chapter":{"type":"object","id":{"type":"int"},"book_id":{"type":"int"},"note":{"type":"text"},
+		"indexes":[{"name":"pk","type":"primary","properties":"id"}]},
+	"languages":{"type":"object_extension","extension":"language","title":{"type":"string"}},
+	"tags":{"type":"object_relation","relation":"tag","book_id":{"type":"int"},"tag_id":{"type":"int"}},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"isbn","type":"unique","properties":"isbn"}]}}`
+
+const diagramDOT = `digraph "book" {
+	rankdir=LR;
+	node [shape=plaintext fontname="Helvetica"];
+	"book" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>book</b></td></tr><tr><td port="id" align="left">id : int PK</td></tr><tr><td port="title" align="left">title : string</td></tr><tr><td port="isbn" align="left">isbn : string UK</td></tr><tr><td port="author_id" align="left">author_id : int FK</td></tr></table>>];
+	"book_chapter" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>book_chapter</b></td></tr><tr><td port="id" align="left">id : int PK</td></tr><tr><td port="book_id" align="left">book_id : int FK</td></tr><tr><td port="note" align="left">note : text</td></tr></table>>];
+	"book_languages" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>book_languages</b></td></tr><tr><td port="title" align="left">title : string</td></tr></table>>];
+	"book_tags" [label=<<table border="0" cellborder="1" cellspacing="0"><tr><td bgcolor="lightgrey"><b>book_tags</b></td></tr><tr><td port="book_id" align="left">book_id : int FK</td></tr><tr><td port="tag_id" align="left">tag_id : int FK</td></tr></table>>];
+	"author" [shape=box style=dashed];
+	"language" [shape=box style=dashed];
+	"tag" [shape=box style=dashed];
+	"book":"author_id" -> "author" [label="codeset" style=dashed];
+	"book_chapter":"book_id" -> "book" [label="parent"];
+	"book_languages":"book_id" -> "book" [label="parent"];
+	"book_languages":"language_id" -> "language" [label="extension" style=bold];
+	"book_tags":"book_id" -> "book" [label="parent"];
+	"book_tags":"tag_id" -> "tag" [label="relation" style=bold];
+}
+`
+
+const diagramMermaid = `erDiagram
+	book {
+		int id PK
+		string title
+		string isbn UK
+		int author_id FK
+	}
+	book_chapter {
+		int id PK
+		int book_id FK
+		text note
+	}
+	book_languages {
+		string title
+	}
+	book_tags {
+		int book_id FK
+		int tag_id FK
+	}
+	book }o--|| author : "codeset author_id"
+	book_chapter }o--|| book : "parent book_id"
+	book_languages }o--|| book : "parent book_id"
+	book_languages }o--|| language : "extension language_id"
+	book_tags }o--|| book : "parent book_id"
+	book_tags }o--|| tag : "relation tag_id"
+`
+
+func TestDefinition2DOT(t *testing.T) {
+	dot, e := Definition2DOT(diagramDefinition, "book")
+	if e != nil || dot != diagramDOT {
+		t.Errorf("Definition2DOT = %v\n%s\nwant\n%s", e, dot, diagramDOT)
+	}
+	if _, e = Definition2DOT(diagramDefinition, "author"); e == nil {
+		t.Error("missing identifier: no error")
+	}
+}
+
+func TestDefinition2Mermaid(t *testing.T) {
+	mermaid, e := Definition2Mermaid(diagramDefinition, "book")
+	if e != nil || mermaid != diagramMermaid {
+		t.Errorf("Definition2Mermaid = %v\n%s\nwant\n%s", e, mermaid, diagramMermaid)
+	}
+}
+
+func TestDefinition2DOTEscape(t *testing.T) {
+	dot, e := Definition2DOT(`{"a<b>":{"type":"object","x&y":{"type":"int"}}}`, "a<b>")
+	if e != nil || !strings.Contains(dot, `<b>a&lt;b&gt;</b>`) || !strings.Contains(dot, `port="x&amp;y"`) {
+		t.Errorf("Definition2DOT = %v\n%s", e, dot)
+	}
+}
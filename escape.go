@@ -0,0 +1,91 @@
+package object
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+/*
+The html generators escape what they paste by its context, as html/template does:
+escText for element text, escAttr for a quoted attribute, escURL for href/src, escCSS for a style value,
+escJS for a quoted javascript string and escJSON for a json string. Markup meant to be written as is
+goes through escText as a TrustedHTML, like the text of the "html" filter component.
+*/
+
+// TrustedHTML is markup from a trusted source, written out unescaped.
+type TrustedHTML = template.HTML
+
+var cssNameRegex = regexp.MustCompile("^-?[a-zA-Z][a-zA-Z0-9-]*$")
+
+// filtered: what html/template writes for a value rejected in its context.
+const filtered = "ZgotmplZ"
+
+func escText(v any) string {
+	switch t := v.(type) {
+	case TrustedHTML:
+		return string(t)
+	case string:
+		return template.HTMLEscapeString(t)
+	}
+	return template.HTMLEscapeString(toString(v))
+}
+
+func escAttr(s string) string {
+	return template.HTMLEscapeString(s)
+}
+
+// escURL: a relative url or one of http, https, mailto, anything else (javascript:) is filtered.
+func escURL(s string) string {
+	if u, err := url.Parse(strings.TrimSpace(s)); err != nil {
+		return "#" + filtered
+	} else if len(u.Scheme) > 0 {
+		switch strings.ToLower(u.Scheme) {
+		case "http", "https", "mailto":
+		default:
+			return "#" + filtered
+		}
+	}
+	return template.HTMLEscapeString(s)
+}
+
+// escCSS: a css value, one able to leave its declaration or load anything is filtered.
+func escCSS(s string) string {
+	if strings.ContainsAny(s, "\x00\"'()/;@[\\]`{}<>") {
+		return filtered
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "expression") || strings.Contains(lower, "mozbinding") {
+		return filtered
+	}
+	return template.HTMLEscapeString(s)
+}
+
+// escStyle: declarations "name:value;name:value", each value by escCSS, a bad name filters the declaration.
+func escStyle(style string) string {
+	dd := []string{}
+	for _, declaration := range strings.Split(style, ";") {
+		if len(strings.TrimSpace(declaration)) == 0 {
+			continue
+		}
+		name, value, ok := strings.Cut(declaration, ":")
+		if !ok || !cssNameRegex.MatchString(strings.TrimSpace(name)) {
+			dd = append(dd, filtered)
+			continue
+		}
+		dd = append(dd, name+":"+escCSS(value))
+	}
+	return strings.Join(dd, ";")
+}
+
+func escJS(s string) string {
+	return template.JSEscapeString(s)
+}
+
+// escJSON: s as the inside of a json string.
+func escJSON(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
@@ -0,0 +1,94 @@
+package object
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const xss = `"><script>alert(1)</script>`
+
+// noMarkup: html holds no raw script tag of xss.
+func noMarkup(t *testing.T, what, html string) {
+	t.Helper()
+	if strings.Contains(html, "<script") {
+		t.Errorf("%s: unescaped markup in %s", what, html)
+	}
+}
+
+func TestIllustration2html(t *testing.T) {
+	html, e := Illustration2html([]byte(`{"columns":2,"images":[{"tag":` + jsonQuote(xss) + `,"src":"a.gif","align":"center;x:expression(1)"},{"tag":"b","src":"javascript:alert(1)"}]}`))
+	if e != nil {
+		t.Fatal(e)
+	}
+	noMarkup(t, "Illustration2html", html)
+	for _, want := range []string{
+		`alt="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`,
+		`src="a.gif"`,
+		`src="#ZgotmplZ"`,
+		`text-align:ZgotmplZ`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Illustration2html lacks %s: %s", want, html)
+		}
+	}
+}
+
+func TestAppendix2html(t *testing.T) {
+	html, e := Appendix2html([]byte(`{"align":"right","valign":"top\"","appendixes":[{"tag":` + jsonQuote(xss) + `,"src":"/u?n=a&b"},{"tag":"b","src":"vbscript:x"}]}`))
+	if e != nil {
+		t.Fatal(e)
+	}
+	noMarkup(t, "Appendix2html", html)
+	for _, want := range []string{
+		`>&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;</a>`,
+		`href="/u?n=a&amp;b"`,
+		`href="#ZgotmplZ"`,
+		`text-align:right;vertical-align:ZgotmplZ`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Appendix2html lacks %s: %s", want, html)
+		}
+	}
+	if _, e = Appendix2html([]byte(`{"appendixes":`)); e == nil {
+		t.Error("Appendix2html of invalid JSON: no error")
+	}
+}
+
+func TestTransformEscape(t *testing.T) {
+	tests := []struct {
+		data, transform, dt string
+	}{
+		{"<b>\nx", "LF2BR", "&lt;b&gt;<br>x"},
+		{`a"b`, "hyperlink#" + xss, `<a href="a&#34;b">&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;</a>`},
+		{"javascript:alert(1)", "hyperlink#go", `<a href="#ZgotmplZ">go</a>`},
+		{`x"><script>`, "poplink#<i>", `<a href="/poplink?z=x&#34;&gt;&lt;script&gt;" target="_blank">&lt;i&gt;</a>`},
+	}
+	for _, tt := range tests {
+		if dt, e := ApplyTransform("title", tt.data, tt.transform, ""); e != nil || dt != tt.dt {
+			t.Errorf("ApplyTransform(%q, %q) = %q %v, want %q", tt.data, tt.transform, dt, e, tt.dt)
+		}
+	}
+}
+
+func TestFilterComponentEscape(t *testing.T) {
+	component := gjson.Parse(`{"caption":` + jsonQuote(xss) + `,"class":"a\" onclick=\"x","editor":"input","property":"title","width":"1px;background:url(x)",
+		"inner":{"property":"summary"},
+		"html":{"text":"<hr>"}}`)
+	html, properties, inputs := filtercomponent2html(`f"<`, component, "", "", "", nil)
+	noMarkup(t, "filtercomponent2html", html)
+	for _, want := range []string{
+		`<span class="caption" style="vertical-align:middle;display:inline-block">&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;:</span>`,
+		`<input id="f&#34;&lt;" class="a&#34; onclick=&#34;x" style="width:ZgotmplZ;vertical-align:middle;display:inline-block;outline:none">`,
+		`<div style="vertical-align:middle;display:inline-block">{{summary}}</div>`,
+		`<hr>`, //html is trusted
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("filtercomponent2html lacks %s: %s", want, html)
+		}
+	}
+	if len(properties) != 2 || len(inputs) != 1 || inputs[0].Caption != xss {
+		t.Errorf("properties %q inputs %+v", properties, inputs)
+	}
+}
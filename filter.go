@@ -0,0 +1,307 @@
+package object
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// filterInputs: the inputs of a filter definition, as Filter2html emits them.
+func filterInputs(definition gjson.Result) (inputs []inputT) {
+	if definition.Get("type").String() == "filter" {
+		definition.ForEach(func(k, v gjson.Result) bool {
+			if v.Type.String() == "JSON" {
+				_, _, ii := filtercomponent2html(k.String(), v, "", "", "", nil)
+				inputs = append(inputs, ii...)
+			}
+			return true //keep iterating
+		})
+	}
+	return
+}
+
+/*
+FilterWhere compiles the submitted values of a filter (by input id, as Filter2html names them) to a WHERE condition
+on table (the table of object) aliased alias, with placeholders of db_type. An empty value leaves its input out.
+
+	daterange	"2024-01-01,2024-01-31", either end may be empty, the end day included; unix seconds for int columns
+	selector	ids "3,5", codes "A01,B02" or dotids (a path "1.5.9" matches 5), by the type of the property;
+			a selector of a filter with "subentity" matches the rows having such a sub-object row
+	treeselector	a selector of a hierarchical codeset, an item matches with the items below it
+	chooser		ids of the options entity "3,5"
+	input		the text LIKE the property, fulltext when a fulltext index of MySQL or PostgreSQL covers it
+	numberrange	"10,99.5" of a decimal or float, either end may be empty, both ends included
+	toggle		"1" or "0" (true/false) of a ^[01]$ flag
+
+A value of a property perm does not show (of the subentity for a sub-object property) is an error, nil perm shows everything.
+*/
+func FilterWhere(filter, object gjson.Result, table string, values map[string]string, db_type int, alias string, perm *PermissionT) (where string, args []any, e error) {
+	sa := sqlArgsT{db_type: db_type}
+	where, e = filterWhere(&sa, filter, object, table, values, alias, perm)
+	args = sa.args
+	return
+}
+
+func filterWhere(sa *sqlArgsT, filter, object gjson.Result, table string, values map[string]string, alias string, perm *PermissionT) (where string, e error) {
+	subentity := filter.Get("subentity").String()
+	ww := []string{}
+	for _, input := range filterInputs(filter) {
+		value := base.TrimBLANK(values[input.FormID])
+		if len(value) == 0 || input.InputType == "wenhao" {
+			continue
+		}
+		o, t, a := object, table, alias
+		property := exactProperty(input.Property)
+		visible := property
+		if (input.InputType == "selector" || input.InputType == "treeselector") && len(subentity) > 0 {
+			o, t, a = object.Get(subentity), table+"_"+subentity, "s"
+			visible = subentity
+		}
+		if !perm.CanSee(visible) {
+			e = errors.New("filter " + input.FormID + ": " + visible + " not visible!")
+			return
+		}
+		p := o.Get(property)
+		if !p.IsObject() || strings.HasPrefix(p.Get("type").String(), "object") {
+			e = errors.New("filter " + input.FormID + ": " + t + "." + property + " not exists!")
+			return
+		}
+		column := sqlIdentifier(property, sa.db_type)
+		if len(a) > 0 {
+			column = a + "." + column
+		}
+		var w string
+		switch input.InputType {
+		case "daterange":
+			w, e = dateRangeWhere(sa, column, p.Get("type").String(), value)
+		case "selector", "treeselector":
+			w, e = selectorWhere(sa, column, p, value, input.InputType == "treeselector")
+			if e == nil && a == "s" {
+				outer := table
+				if len(alias) > 0 {
+					outer = alias
+				}
+				w = "EXISTS (SELECT 1 FROM " + sqlIdentifier(t, sa.db_type) + " s WHERE s." + sqlIdentifier(table+"_id", sa.db_type) + "=" + outer + ".id AND " + w + ")"
+			}
+		case "chooser":
+			w, e = idsWhere(sa, column, value)
+		case "input":
+			w = textWhere(sa, o, property, column, a, value)
+		case "numberrange":
+			if ptype := p.Get("type").String(); ptype != "decimal" && ptype != "float" {
+				e = errors.New("numberrange: " + property + " is not decimal or float!")
+				break
+			}
+			w, e = numberRangeWhere(sa, column, value)
+		case "toggle":
+			if p.Get("pattern").String() != "^[01]$" {
+				e = errors.New("toggle: " + property + " has no ^[01]$ pattern!")
+				break
+			}
+			switch strings.ToLower(value) {
+			case "1", "true":
+				w = column + "=1"
+			case "0", "false":
+				w = column + "=0"
+			default:
+				e = errors.New("toggle " + value + " syntax error!")
+			}
+		default:
+			e = errors.New("unknown editor " + input.InputType)
+		}
+		if e != nil {
+			e = errors.New("filter " + input.FormID + ": " + e.Error())
+			return
+		}
+		ww = append(ww, w)
+	}
+	where = strings.Join(ww, " AND ")
+	return
+}
+
+func bindList(sa *sqlArgsT, vv []string) string {
+	pp := []string{}
+	for _, v := range vv {
+		pp = append(pp, sa.bind(v))
+	}
+	return strings.Join(pp, ",")
+}
+
+func filterIDs(value string) (ids []int64, e error) {
+	for _, s := range splitList(value) {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			e = errors.New("id " + s + " syntax error!")
+			return
+		}
+		ids = append(ids, id)
+	}
+	return
+}
+
+/*
+selectorWhere: the items of value by the type of p. Under tree an id takes the ids below it in the options codeset
+(its parentid hierarchy) and a code the codes it prefixes, a dotids path matches any item of it already.
+*/
+func selectorWhere(sa *sqlArgsT, column string, p gjson.Result, value string, tree bool) (w string, e error) {
+	switch p.Get("type").String() {
+	case "string":
+		if tree {
+			oo := []string{}
+			for _, code := range splitList(value) {
+				oo = append(oo, column+" LIKE "+sa.bind(likeEscape(code)+"%")+" ESCAPE '!'")
+			}
+			w = "(" + strings.Join(oo, " OR ") + ")"
+		} else {
+			w = column + " IN (" + bindList(sa, splitList(value)) + ")"
+		}
+	case "dotids":
+		ids, err := filterIDs(value)
+		if err != nil {
+			e = err
+			return
+		}
+		oo := []string{}
+		for _, id := range ids {
+			s := strconv.FormatInt(id, 10)
+			oo = append(oo, column+"="+sa.bind(s), column+" LIKE "+sa.bind(s+".%"), column+" LIKE "+sa.bind("%."+s), column+" LIKE "+sa.bind("%."+s+".%"))
+		}
+		w = "(" + strings.Join(oo, " OR ") + ")"
+	default:
+		if w, e = idsWhere(sa, column, value); e != nil || !tree {
+			return
+		}
+		codeset := p.Get("options").String()
+		if !identifierRegex.MatchString(codeset) {
+			e = errors.New("options " + codeset + " syntax error!")
+			return
+		}
+		in := strings.TrimPrefix(w, column+" ")
+		cs := sqlIdentifier(codeset, sa.db_type)
+		w = column + " IN (WITH RECURSIVE t(id) AS (SELECT id FROM " + cs + " WHERE id " + in +
+			" UNION ALL SELECT c.id FROM " + cs + " c JOIN t ON c.parentid=t.id) SELECT id FROM t)"
+	}
+	return
+}
+
+func idsWhere(sa *sqlArgsT, column, value string) (w string, e error) {
+	ids, err := filterIDs(value)
+	if err != nil {
+		e = err
+		return
+	}
+	pp := []string{}
+	for _, id := range ids {
+		pp = append(pp, sa.bind(id))
+	}
+	w = column + " IN (" + strings.Join(pp, ",") + ")"
+	return
+}
+
+// filterDay: a day of a daterange, yyyy-mm-dd or what base.Str20time reads.
+func filterDay(s string) (day time.Time, e error) {
+	if day, e = time.ParseInLocation("2006-01-02", s, time.Local); e != nil {
+		if day, e = base.Str20time(s); e == nil && day.IsZero() {
+			e = errors.New("date " + s + " syntax error!")
+		}
+	}
+	return
+}
+
+func dateRangeWhere(sa *sqlArgsT, column, p_type, value string) (w string, e error) {
+	from, to, _ := strings.Cut(value, ",")
+	ww := []string{}
+	for i, s := range []string{base.TrimBLANK(from), base.TrimBLANK(to)} {
+		if len(s) == 0 {
+			continue
+		}
+		day, err := filterDay(s)
+		if err != nil {
+			e = err
+			return
+		}
+		op := ">="
+		if i == 1 {
+			day, op = day.AddDate(0, 0, 1), "<"
+		}
+		var v any = day.Format("2006-01-02 15:04:05")
+		if p_type == "int" || p_type == "long" {
+			v = day.Unix()
+		}
+		ww = append(ww, column+op+sa.bind(v))
+	}
+	if len(ww) == 0 {
+		e = errors.New("daterange " + value + " syntax error!")
+		return
+	}
+	w = strings.Join(ww, " AND ")
+	return
+}
+
+// textWhere: fulltext over the columns of the fulltext index covering property, else LIKE.
+func textWhere(sa *sqlArgsT, o gjson.Result, property, column, alias, value string) (w string) {
+	if sa.db_type == base.MySQL || sa.db_type == PostgreSQL {
+		for _, idx := range o.Get("indexes").Array() {
+			if idx.Get("type").String() != "fulltext" {
+				continue
+			}
+			props := []string{}
+			for _, prop := range splitList(idx.Get("properties").String()) {
+				prop, _, _ = strings.Cut(prop, " ")
+				props = append(props, prop)
+			}
+			if exists, _ := base.In_array(property, props); !exists {
+				continue
+			}
+			cc := []string{}
+			for _, prop := range props {
+				c := sqlIdentifier(prop, sa.db_type)
+				if len(alias) > 0 {
+					c = alias + "." + c
+				}
+				if sa.db_type == PostgreSQL {
+					c = "coalesce(" + c + ",'')"
+				}
+				cc = append(cc, c)
+			}
+			if sa.db_type == PostgreSQL { //the expression of the GIN index
+				w = "to_tsvector('simple'," + strings.Join(cc, "||' '||") + ") @@ plainto_tsquery('simple'," + sa.bind(value) + ")"
+			} else {
+				w = "MATCH(" + strings.Join(cc, ",") + ") AGAINST(" + sa.bind(value) + " IN BOOLEAN MODE)"
+			}
+			return
+		}
+	}
+	w = column + " LIKE " + sa.bind("%"+likeEscape(value)+"%") + " ESCAPE '!'"
+	return
+}
+
+func numberRangeWhere(sa *sqlArgsT, column, value string) (w string, e error) {
+	from, to, _ := strings.Cut(value, ",")
+	ww := []string{}
+	for i, s := range []string{base.TrimBLANK(from), base.TrimBLANK(to)} {
+		if len(s) == 0 {
+			continue
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			e = errors.New("number " + s + " syntax error!")
+			return
+		}
+		op := ">="
+		if i == 1 {
+			op = "<="
+		}
+		ww = append(ww, column+op+sa.bind(f))
+	}
+	if len(ww) == 0 {
+		e = errors.New("numberrange " + value + " syntax error!")
+		return
+	}
+	w = strings.Join(ww, " AND ")
+	return
+}
@@ -0,0 +1,224 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+const filterObject = `{"type":"object",
+	"id":{"type":"int"},"title":{"type":"string","size":50},"body":{"type":"text"},"code":{"type":"string","size":20},
+	"time_released":{"type":"time"},"day_released":{"type":"long"},"status_id":{"type":"int","options":"status"},
+	"region_code":{"type":"string","size":10,"codeset":"region"},"path":{"type":"dotids"},"region_id":{"type":"int","options":"region"},
+	"user_id":{"type":"int"},"price":{"type":"decimal"},"released":{"type":"int","pattern":"^[01]$"},
+	"tags":{"type":"object","tag_id":{"type":"int","options":"tag"}},
+	"indexes":[{"name":"ft","type":"fulltext","properties":"title,body"}]}`
+
+type filterCaseT struct {
+	name   string
+	editor string //the filter component f
+	value  string
+	where  string //MySQL with ? placeholders
+	pg     string //PostgreSQL with $n placeholders
+	args   []any
+}
+
+func checkFilterWhere(t *testing.T, tests []filterCaseT) {
+	t.Helper()
+	object := gjson.Parse(filterObject)
+	for _, tt := range tests {
+		filter := gjson.Parse(`{"type":"filter","f":` + tt.editor + `}`)
+		for _, db := range []struct {
+			db_type int
+			where   string
+		}{{base.MySQL, tt.where}, {PostgreSQL, tt.pg}} {
+			where, args, e := FilterWhere(filter, object, "article", map[string]string{"f": tt.value}, db.db_type, "o", nil)
+			if e != nil || where != db.where || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("%s %d:\n got %q %v %v\nwant %q %v", tt.name, db.db_type, where, args, e, db.where, tt.args)
+			}
+		}
+	}
+}
+
+func TestFilterWhere(t *testing.T) {
+	day := func(y int, m time.Month, d int) int64 { return time.Date(y, m, d, 0, 0, 0, 0, time.Local).Unix() }
+	checkFilterWhere(t, []filterCaseT{
+		{"daterange", `{"editor":"daterange","property":"time_released"}`, "2024-01-01,2024-01-31",
+			"o.`time_released`>=? AND o.`time_released`<?", `o."time_released">=$1 AND o."time_released"<$2`,
+			[]any{"2024-01-01 00:00:00", "2024-02-01 00:00:00"}},
+		{"daterange from", `{"editor":"daterange","property":"time_released"}`, "2024-01-01,",
+			"o.`time_released`>=?", `o."time_released">=$1`, []any{"2024-01-01 00:00:00"}},
+		{"daterange unix", `{"editor":"daterange","property":"day_released"}`, ",2024-02-29",
+			"o.`day_released`<?", `o."day_released"<$1`, []any{day(2024, 3, 1)}},
+		{"selector ids", `{"editor":"selector","property":"status_id"}`, "3, 5",
+			"o.`status_id` IN (?,?)", `o."status_id" IN ($1,$2)`, []any{int64(3), int64(5)}},
+		{"selector codes", `{"editor":"selector","property":"region_code"}`, "A01,B02",
+			"o.`region_code` IN (?,?)", `o."region_code" IN ($1,$2)`, []any{"A01", "B02"}},
+		{"selector dotids", `{"editor":"selector","property":"path"}`, "5",
+			"(o.`path`=? OR o.`path` LIKE ? OR o.`path` LIKE ? OR o.`path` LIKE ?)",
+			`(o."path"=$1 OR o."path" LIKE $2 OR o."path" LIKE $3 OR o."path" LIKE $4)`,
+			[]any{"5", "5.%", "%.5", "%.5.%"}},
+		{"chooser", `{"editor":"chooser","property":"user_id"}`, "7",
+			"o.`user_id` IN (?)", `o."user_id" IN ($1)`, []any{int64(7)}},
+		{"input", `{"editor":"input","property":"code"}`, "50%_off!",
+			"o.`code` LIKE ? ESCAPE '!'", `o."code" LIKE $1 ESCAPE '!'`, []any{"%50!%!_off!!%"}},
+		{"input fulltext", `{"editor":"input","property":"title"}`, "go sql",
+			"MATCH(o.`title`,o.`body`) AGAINST(? IN BOOLEAN MODE)",
+			`to_tsvector('simple',coalesce(o."title",'')||' '||coalesce(o."body",'')) @@ plainto_tsquery('simple',$1)`,
+			[]any{"go sql"}},
+		{"empty", `{"editor":"input","property":"code"}`, " ", "", "", nil},
+	})
+}
+
+func TestFilterWhereSubentity(t *testing.T) {
+	object := gjson.Parse(filterObject)
+	filter := gjson.Parse(`{"type":"filter","subentity":"tags","tag":{"editor":"selector","property":"tag_id"},"kw":{"editor":"input","property":"code"}}`)
+	values := map[string]string{"tag": "4,6", "kw": "x"}
+	where, args, e := FilterWhere(filter, object, "article", values, PostgreSQL, "o", nil)
+	want := `EXISTS (SELECT 1 FROM "article_tags" s WHERE s."article_id"=o.id AND s."tag_id" IN ($1,$2)) AND o."code" LIKE $3 ESCAPE '!'`
+	if e != nil || where != want || !reflect.DeepEqual(args, []any{int64(4), int64(6), "%x%"}) {
+		t.Errorf("got %q %v %v\nwant %q", where, args, e, want)
+	}
+	where, _, e = FilterWhere(filter, object, "article", values, base.MySQL, "", nil)
+	want = "EXISTS (SELECT 1 FROM `article_tags` s WHERE s.`article_id`=article.id AND s.`tag_id` IN (?,?)) AND `code` LIKE ? ESCAPE '!'"
+	if e != nil || where != want {
+		t.Errorf("no alias: got %q %v\nwant %q", where, e, want)
+	}
+}
+
+func TestFilterWhereErrors(t *testing.T) {
+	object := gjson.Parse(filterObject)
+	for _, tt := range []struct {
+		editor, value string
+	}{
+		{`{"editor":"daterange","property":"time_released"}`, "yesterday,"},
+		{`{"editor":"daterange","property":"time_released"}`, ","},
+		{`{"editor":"selector","property":"status_id"}`, "3,x"},
+		{`{"editor":"selector","property":"path"}`, "1.5"},
+		{`{"editor":"chooser","property":"user_id"}`, "1 OR 1=1"},
+		{`{"editor":"input","property":"nosuch"}`, "x"},
+		{`{"editor":"input","property":"tags"}`, "x"},
+		{`{"editor":"slider","property":"price"}`, "1"},
+	} {
+		filter := gjson.Parse(`{"type":"filter","f":` + tt.editor + `}`)
+		if _, _, e := FilterWhere(filter, object, "article", map[string]string{"f": tt.value}, base.MySQL, "o", nil); e == nil || !strings.HasPrefix(e.Error(), "filter f: ") {
+			t.Errorf("%s %q: %v", tt.editor, tt.value, e)
+		}
+	}
+	filter := gjson.Parse(`{"type":"filter","f":{"editor":"selector","property":"status_id"}}`)
+	perm := &PermissionT{Visible: []string{"title"}}
+	if _, _, e := FilterWhere(filter, object, "article", map[string]string{"f": "1"}, base.MySQL, "o", perm); e == nil {
+		t.Error("status_id not visible: no error")
+	}
+}
+
+func TestFilterWhereEditors(t *testing.T) {
+	checkFilterWhere(t, []filterCaseT{
+		{"numberrange", `{"editor":"numberrange","property":"price"}`, "10,99.5",
+			"o.`price`>=? AND o.`price`<=?", `o."price">=$1 AND o."price"<=$2`, []any{10.0, 99.5}},
+		{"numberrange from", `{"editor":"numberrange","property":"price"}`, "-5,",
+			"o.`price`>=?", `o."price">=$1`, []any{-5.0}},
+		{"numberrange to", `{"editor":"numberrange","property":"price"}`, ", 7",
+			"o.`price`<=?", `o."price"<=$1`, []any{7.0}},
+		{"toggle on", `{"editor":"toggle","property":"released"}`, "true",
+			"o.`released`=1", `o."released"=1`, nil},
+		{"toggle off", `{"editor":"toggle","property":"released"}`, "0",
+			"o.`released`=0", `o."released"=0`, nil},
+		{"treeselector codes", `{"editor":"treeselector","property":"region_code"}`, "A0_,B",
+			"(o.`region_code` LIKE ? ESCAPE '!' OR o.`region_code` LIKE ? ESCAPE '!')",
+			`(o."region_code" LIKE $1 ESCAPE '!' OR o."region_code" LIKE $2 ESCAPE '!')`, []any{"A0!_%", "B%"}},
+		{"treeselector ids", `{"editor":"treeselector","property":"region_id"}`, "3,5",
+			"o.`region_id` IN (WITH RECURSIVE t(id) AS (SELECT id FROM `region` WHERE id IN (?,?) UNION ALL SELECT c.id FROM `region` c JOIN t ON c.parentid=t.id) SELECT id FROM t)",
+			`o."region_id" IN (WITH RECURSIVE t(id) AS (SELECT id FROM "region" WHERE id IN ($1,$2) UNION ALL SELECT c.id FROM "region" c JOIN t ON c.parentid=t.id) SELECT id FROM t)`,
+			[]any{int64(3), int64(5)}},
+	})
+	object := gjson.Parse(filterObject)
+	for _, tt := range []struct {
+		editor, value string
+	}{
+		{`{"editor":"numberrange","property":"price"}`, "ten,"},
+		{`{"editor":"numberrange","property":"price"}`, "1,2x"},
+		{`{"editor":"numberrange","property":"price"}`, ","},
+		{`{"editor":"toggle","property":"released"}`, "yes"},
+		{`{"editor":"toggle","property":"status_id"}`, "1"},      //no ^[01]$ pattern
+		{`{"editor":"numberrange","property":"user_id"}`, "1,2"}, //int
+		{`{"editor":"numberrange","property":"title"}`, "1,2"},
+		{`{"editor":"treeselector","property":"user_id"}`, "1"}, //no options codeset
+	} {
+		filter := gjson.Parse(`{"type":"filter","f":` + tt.editor + `}`)
+		if _, _, e := FilterWhere(filter, object, "article", map[string]string{"f": tt.value}, base.MySQL, "o", nil); e == nil {
+			t.Errorf("%s %q: no error", tt.editor, tt.value)
+		}
+	}
+}
+
+// TestTreeselectorSQLite runs the recursive CTE of a treeselector: an item matches with the items below it.
+func TestTreeselectorSQLite(t *testing.T) {
+	db := openTestDB(t)
+	for _, query := range []string{
+		"CREATE TABLE region(id INTEGER PRIMARY KEY,parentid INTEGER)",
+		"INSERT INTO region(id,parentid) VALUES(1,0),(2,1),(3,2),(4,0),(5,4)",
+		"CREATE TABLE article(id INTEGER PRIMARY KEY,region_id INTEGER)",
+		"INSERT INTO article(id,region_id) VALUES(10,1),(11,2),(12,3),(13,4),(14,5)",
+	} {
+		if _, e := db.Exec(query); e != nil {
+			t.Fatal(e)
+		}
+	}
+	filter := gjson.Parse(`{"type":"filter","f":{"editor":"treeselector","property":"region_id"}}`)
+	for value, want := range map[string][]int64{"2": {11, 12}, "1,5": {10, 11, 12, 14}, "3": {12}} {
+		where, args, e := FilterWhere(filter, gjson.Parse(filterObject), "article", map[string]string{"f": value}, base.SQLite, "o", nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		rows, e := db.Query("SELECT o.id FROM article o WHERE "+where+" ORDER BY o.id", args...)
+		if e != nil {
+			t.Fatal(e)
+		}
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("treeselector %s = %v, want %v", value, ids, want)
+		}
+	}
+}
+
+func TestFilterComponentEditors(t *testing.T) {
+	tests := []struct {
+		editor, html string
+	}{
+		{`{"editor":"treeselector:leaf","property":"region_id","width":"120px"}`,
+			`<div id="f" class="h_treeselector" tabindex="0" style="width:120px;vertical-align:middle;display:inline-block;outline:none"></div>`},
+		{`{"editor":"numberrange","property":"price"}`,
+			`<span id="f" class="numberrange" tabindex="10" style="vertical-align:middle;display:inline-block;outline:none"></span>`},
+		{`{"editor":"toggle","property":"released","default":"1"}`,
+			`<span id="f" class="toggle" tabindex="10" style="vertical-align:middle;display:inline-block"></span>`},
+	}
+	for _, tt := range tests {
+		component := gjson.Parse(tt.editor)
+		html, properties, inputs := filtercomponent2html("f", component, "", "", "", nil)
+		if !strings.Contains(html, tt.html) {
+			t.Errorf("%s: html %s", tt.editor, html)
+		}
+		property := component.Get("property").String()
+		if !reflect.DeepEqual(properties, []string{property}) || len(inputs) != 1 || inputs[0].FormID != "f" || inputs[0].Property != property {
+			t.Errorf("%s: properties %q inputs %+v", tt.editor, properties, inputs)
+		}
+	}
+	_, _, inputs := filtercomponent2html("f", gjson.Parse(tests[0].editor), "", "", "", nil)
+	if inputs[0].InputType != "treeselector" || inputs[0].InputParam != "leaf" {
+		t.Errorf("treeselector input %+v", inputs[0])
+	}
+	if html, _, _ := filtercomponent2html("f", gjson.Parse(tests[1].editor), "", "", "", &PermissionT{Visible: []string{"title"}}); len(html) > 0 {
+		t.Errorf("numberrange of a property not visible: %s", html)
+	}
+}
@@ -0,0 +1,215 @@
+package object
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+/*
+GridQueryT is what a page of a grid is read with:
+Grid the grid definition, Filter the filter definition with FilterValues its submitted values by input id,
+Language_id the language of the language_adaptive properties (a missing translation falls back to the row),
+Limit the "limit" array restricting the rows for UserType with the session@ values of Session (unset: the "limit" of the table),
+Perm the columns and reference_properties shown and the properties filtered by (nil all).
+Sort overrides the grid "sort": "title,-id" or "title asc,id desc". Page counts from 1, RowsPerPage 0 takes the grid rows_per_page.
+*/
+type GridQueryT struct {
+	Table        string //"" the object itself
+	Grid         gjson.Result
+	Filter       gjson.Result
+	FilterValues map[string]string
+	Language_id  string
+	Limit        gjson.Result
+	UserType     int
+	Session      map[string]any
+	Perm         *PermissionT
+	Sort         string
+	Page         int
+	RowsPerPage  int
+}
+
+/*
+GridSelect builds the SELECT of a page of the grid and the COUNT of all its rows, both taking args.
+A row carries id, the grid columns and the reference_properties by property: "user_id^user.name" is
+the name of the user joined on user_id.
+*/
+func (repo *Repository) GridSelect(gq GridQueryT) (query, count string, args []any, e error) {
+	tablename := gq.Table
+	if len(tablename) == 0 {
+		tablename = repo.Identifier
+	}
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	if gq.Grid.Get("type").String() != "grid" {
+		e = errors.New("grid syntax error!")
+		return
+	}
+	spec := ParseGridSpec(&gq.Grid, repo.Identifier, "", "", gq.Perm)
+	sa := sqlArgsT{db_type: repo.DB_type}
+	joins := ""
+	adaptive := map[string]bool{}
+	if len(gq.Language_id) > 0 {
+		if _, lt, aa, err := repo.languageTable(tablename); err == nil && len(aa) > 0 {
+			joins += " LEFT JOIN " + repo.ident(lt.name) + " ol ON ol." + repo.ident(tablename+"_id") + "=o.id AND ol.language_id=" + sa.bind(gq.Language_id)
+			for _, c := range aa {
+				adaptive[c] = true
+			}
+		}
+	}
+	names, exprs := []string{}, map[string]string{}
+	add := func(name, expr string) {
+		if _, ok := exprs[name]; !ok {
+			names = append(names, name)
+			exprs[name] = expr
+		}
+	}
+	column := func(c string) string {
+		if adaptive[c] {
+			return "COALESCE(ol." + repo.ident(c) + ",o." + repo.ident(c) + ")"
+		}
+		return "o." + repo.ident(c)
+	}
+	add("id", "o.id")
+	properties := []string{}
+	for _, col := range spec.Columns {
+		addUnique(&properties, []string{col.Property})
+	}
+	ncolumns := len(properties)
+	addUnique(&properties, spec.References)
+	lookups := map[string]string{} //local^table: alias
+	for i, p := range properties {
+		if local, remote, ok := strings.Cut(p, "^"); ok {
+			table, c, _ := strings.Cut(remote, ".")
+			if !rt.hasColumn(local) || !identifierRegex.MatchString(table) || !identifierRegex.MatchString(c) {
+				e = errors.New("grid property " + p + " syntax error!")
+				return
+			}
+			add(local, column(local))
+			alias, ok := lookups[local+"^"+table]
+			if !ok {
+				alias = "j" + strconv.Itoa(len(lookups)+1)
+				lookups[local+"^"+table] = alias
+				joins += " LEFT JOIN " + repo.ident(table) + " " + alias + " ON " + alias + ".id=o." + repo.ident(local)
+			}
+			add(p, alias+"."+repo.ident(c))
+		} else if rt.hasColumn(p) {
+			add(p, column(p))
+		} else if i < ncolumns && len(p) > 0 { //a reference may be a property of a table joined elsewhere
+			e = errors.New(rt.name + "." + p + " not exists!")
+			return
+		}
+	}
+	ww := []string{}
+	fw, err := filterWhere(&sa, gq.Filter, rt.o, rt.name, gq.FilterValues, "o", gq.Perm)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(fw) > 0 {
+		ww = append(ww, fw)
+	}
+	limit := gq.Limit
+	if !limit.Exists() {
+		limit = rt.o.Get("limit")
+	}
+	aw, err := userAccessWhere(&sa, gq.UserType, limit, "o", gq.Session)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(aw) > 0 {
+		ww = append(ww, aw)
+	}
+	from := " FROM " + repo.ident(rt.name) + " o" + joins
+	if len(ww) > 0 {
+		from += " WHERE " + strings.Join(ww, " AND ")
+	}
+	oo, err := gridOrder(gq.Sort, spec.Sort, exprs)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(oo) == 0 {
+		if rt.hierarchical {
+			oo = []string{"o.parentid", "o.ordinalposition"}
+		} else {
+			oo = []string{"o.id"}
+		}
+	}
+	ss := []string{}
+	for _, name := range names {
+		ss = append(ss, exprs[name]+" AS "+repo.ident(name))
+	}
+	query = "SELECT " + strings.Join(ss, ",") + from + " ORDER BY " + strings.Join(oo, ",")
+	rows_per_page := gq.RowsPerPage
+	if rows_per_page == 0 {
+		rows_per_page = spec.RowsPerPage
+	}
+	if rows_per_page > 0 {
+		page := gq.Page
+		if page < 1 {
+			page = 1
+		}
+		query += " LIMIT " + strconv.Itoa(rows_per_page) + " OFFSET " + strconv.Itoa((page-1)*rows_per_page)
+	}
+	count = "SELECT COUNT(*)" + from
+	args = sa.args
+	return
+}
+
+// gridOrder: the ORDER BY terms of sort (else of the grid sort), each term a selected property.
+func gridOrder(sort, gridsort string, exprs map[string]string) (oo []string, e error) {
+	if len(sort) == 0 {
+		sort = gridsort
+	}
+	for _, term := range splitList(sort) {
+		name, desc := term, false
+		if strings.HasPrefix(term, "-") {
+			name, desc = term[1:], true
+		} else if ff := strings.Fields(term); len(ff) == 2 {
+			switch strings.ToLower(ff[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				e = errors.New("sort " + term + " syntax error!")
+				return
+			}
+			name = ff[0]
+		}
+		expr, ok := exprs[name]
+		if !ok {
+			e = errors.New("sort " + name + ": not a column of the grid!")
+			return
+		}
+		if desc {
+			expr += " DESC"
+		}
+		oo = append(oo, expr)
+	}
+	return
+}
+
+// GridRows reads a page of the grid and the number of all its rows.
+func (repo *Repository) GridRows(gq GridQueryT) (rows []RowT, total int64, e error) {
+	query, count, args, err := repo.GridSelect(gq)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = repo.DB.QueryRow(count, args...).Scan(&total); e != nil {
+		return
+	}
+	tablename := gq.Table
+	if len(tablename) == 0 {
+		tablename = repo.Identifier
+	}
+	rows, e = repo.query(repo.DB, repo.tables[tablename], query, args...)
+	return
+}
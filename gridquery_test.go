@@ -0,0 +1,121 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+const gridDefinition = `{"article":{"type":"object",
+	"id":{"type":"int"},"title":{"type":"string","size":50},"user_id":{"type":"int"},"status_id":{"type":"int"},"released":{"type":"int"},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"}]}}`
+
+const gridGrid = `{"type":"grid","rows_per_page":2,"sort":"-id",
+	"title":{"property":"title","caption":"title"},
+	"author":{"property":"user_id^user.name","caption":"author"},
+	"ops":{"caption":"ops","actions":[{"action":"remove","condition":"released=0"}]}}`
+
+const gridFilter = `{"type":"filter","kw":{"caption":"key","editor":"input","property":"title"},"st":{"editor":"selector","property":"status_id"}}`
+
+func newGridRepository(t *testing.T) (repo *Repository) {
+	t.Helper()
+	db := openTestDB(t)
+	if _, e := NewInstaller(db, base.SQLite, "tester").Install(gridDefinition, "article"); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := db.Exec("CREATE TABLE user(id INTEGER PRIMARY KEY,name varchar(50))"); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := db.Exec("INSERT INTO user(id,name) VALUES(1,'ann'),(2,'bob')"); e != nil {
+		t.Fatal(e)
+	}
+	repo, e := NewRepository(db, base.SQLite, gridDefinition, "article")
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i, title := range []string{"alpha", "beta", "gamma", "alps", "delta"} {
+		if _, e = repo.Insert("article", RowT{"title": title, "user_id": i%2 + 1, "status_id": i % 3 * 3, "released": 0}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	return
+}
+
+func TestGridSelect(t *testing.T) {
+	repo := newGridRepository(t)
+	gq := GridQueryT{Grid: gjson.Parse(gridGrid), Filter: gjson.Parse(gridFilter), FilterValues: map[string]string{"kw": "al"},
+		Limit: gjson.Parse(`[{"usertype":"all","access":"status_id != 6 OR user_id = session@user_id"}]`), Session: map[string]any{"user_id": 2}}
+	query, count, args, e := repo.GridSelect(gq)
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := "SELECT o.id AS `id`,o.`title` AS `title`,o.`user_id` AS `user_id`,j1.`name` AS `user_id^user.name`,o.`released` AS `released`" +
+		" FROM `article` o LEFT JOIN `user` j1 ON j1.id=o.`user_id` WHERE o.`title` LIKE ? ESCAPE '!' AND (o.`status_id`!=6 OR o.`user_id`=?)"
+	if query != want+" ORDER BY o.id DESC LIMIT 2 OFFSET 0" {
+		t.Errorf("query = %s", query)
+	}
+	if count != "SELECT COUNT(*)"+want[strings.Index(want, " FROM "):] {
+		t.Errorf("count = %s", count)
+	}
+	if !reflect.DeepEqual(args, []any{"%al%", 2}) {
+		t.Errorf("args = %v", args)
+	}
+	rows, total, e := repo.GridRows(gq)
+	if e != nil {
+		t.Fatal(e)
+	}
+	titles := []string{}
+	for _, row := range rows {
+		titles = append(titles, toString(row["title"])+"/"+toString(row["user_id^user.name"]))
+	}
+	if total != 2 || !reflect.DeepEqual(titles, []string{"alps/bob", "alpha/ann"}) {
+		t.Errorf("GridRows = %d %q", total, titles)
+	}
+
+	gq.FilterValues, gq.Sort, gq.Page = nil, "title", 2
+	if rows, total, e = repo.GridRows(gq); e != nil || total != 4 || len(rows) != 2 || toString(rows[0]["title"]) != "beta" {
+		t.Errorf("page 2 = %d %v %v", total, rows, e)
+	}
+	gq.Sort = "body"
+	if _, _, _, e = repo.GridSelect(gq); e == nil {
+		t.Error("sort by a property not selected: no error")
+	}
+}
+
+func TestGridSelectPermission(t *testing.T) {
+	repo := newGridRepository(t)
+	gq := GridQueryT{Grid: gjson.Parse(gridGrid), Filter: gjson.Parse(gridFilter), FilterValues: map[string]string{"kw": "al"},
+		Perm: &PermissionT{Operations: []string{"*"}, Visible: []string{"title"}}}
+	query, _, _, e := repo.GridSelect(gq)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if want := "SELECT o.id AS `id`,o.`title` AS `title` FROM"; !strings.HasPrefix(query, want) {
+		t.Errorf("query selects a property not visible: %s", query)
+	}
+	rows, _, e := repo.GridRows(gq)
+	if e != nil || len(rows) == 0 {
+		t.Fatalf("GridRows = %v %v", rows, e)
+	}
+	for _, row := range rows {
+		if _, ok := row["released"]; ok {
+			t.Errorf("row carries released, a reference property not visible: %v", row)
+		}
+	}
+	gq.Sort = "released"
+	if _, _, _, e = repo.GridSelect(gq); e == nil {
+		t.Error("sort by a reference property not visible: no error")
+	}
+	gq.Sort = ""
+	gq.Perm.Visible = append(gq.Perm.Visible, "released")
+	if query, _, _, e = repo.GridSelect(gq); e != nil || !strings.Contains(query, "o.`released` AS `released`") {
+		t.Errorf("released visible: query %s %v", query, e)
+	}
+	gq.FilterValues["st"] = "6"
+	if _, _, _, e = repo.GridSelect(gq); e == nil || !strings.Contains(e.Error(), "status_id not visible") {
+		t.Errorf("filter by a property not visible = %v", e)
+	}
+}
@@ -0,0 +1,215 @@
+package object
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+GridSpecT is a grid definition as data, for a client rendering the grid itself:
+
+	{"rows_per_page":20,"sort":"-id",
+	 "columns":[{"name":"title","property":"title","caption":"Title","visible":true,
+	 	"actions":[{"action":"remove","icon":"fa-trash","condition":"released=0"}]}],
+	 "shortcuts":[{"action":"addnew","icon":"fa-plus","caption":"New","hotkey":true}],
+	 "reference_properties":["id","released"],"dependencies":["popform"]}
+
+A shortcut with hotkey is also a button on the toolbar. JS is the adapter to the jQuery grid ParseGrid feeds.
+*/
+type GridSpecT struct {
+	RowsPerPage  int             `json:"rows_per_page"`
+	Sort         string          `json:"sort,omitempty"`
+	Columns      []GridColumnT   `json:"columns"`
+	Shortcuts    []GridShortcutT `json:"shortcuts"`
+	References   []string        `json:"reference_properties"` //properties the actions need besides the columns
+	Dependencies []string        `json:"dependencies"`         //widgets of the actions and shortcuts
+}
+
+type GridColumnT struct {
+	Name       string        `json:"name"`
+	Property   string        `json:"property"`
+	Caption    string        `json:"caption"`
+	Query      string        `json:"query,omitempty"`
+	Hyperlink  string        `json:"hyperlink,omitempty"`
+	Mark       string        `json:"mark,omitempty"`
+	Onclick    string        `json:"onclick,omitempty"`
+	Order      string        `json:"order,omitempty"`
+	Render     string        `json:"render,omitempty"`
+	Align      string        `json:"align,omitempty"`
+	Width      string        `json:"width,omitempty"`
+	Transform  string        `json:"transform,omitempty"`
+	Comparison string        `json:"comparison,omitempty"`
+	Fixed      string        `json:"fixed,omitempty"`
+	Visible    bool          `json:"visible"`
+	Actions    []GridActionT `json:"actions,omitempty"`
+}
+
+type GridActionT struct {
+	Action    string `json:"action"`
+	Icon      string `json:"icon,omitempty"`
+	Scene     string `json:"scene,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+	Condition string `json:"condition,omitempty"` //see ParseCondition
+}
+
+type GridShortcutT struct {
+	Action  string `json:"action"`
+	Icon    string `json:"icon,omitempty"`
+	Scene   string `json:"scene,omitempty"`
+	Caption string `json:"caption"`
+	Hotkey  bool   `json:"hotkey,omitempty"`
+	Style   string `json:"style,omitempty"`
+}
+
+// ParseGridSpec reads the grid definition, without what perm does not allow (nil allows everything).
+func ParseGridSpec(grid *gjson.Result, identifier, gridscene, clientlanguage_code string, perm *PermissionT) (spec GridSpecT) {
+	spec = GridSpecT{Columns: []GridColumnT{}, Shortcuts: []GridShortcutT{}, References: []string{}, Dependencies: []string{}} //[] in JSON, not null
+	if grid.Get("type").String() != "grid" {
+		return
+	}
+	spec.RowsPerPage = int(grid.Get("rows_per_page").Int())
+	if spec.RowsPerPage == 0 {
+		spec.RowsPerPage = base.Str2int(base.GetConfigurationSimple("UI_ROWSPERPAGE"))
+	}
+	spec.Sort = grid.Get("sort").String()
+	refers := []string{"id"}
+	grid.ForEach(func(k, v gjson.Result) bool {
+		name := k.String()
+		if name == "_shortcut_" {
+			if v.IsArray() {
+				for _, a := range v.Array() {
+					var s GridShortcutT
+					s.Icon = a.Get("icon").String()
+					s.Action = a.Get("action").String()
+					if !perm.Allow(s.Action) {
+						continue
+					}
+					s.Caption = base.LanguageLabel(a.Get("caption").String(), clientlanguage_code)
+					s.Hotkey = a.Get("hotkey").Bool()
+					s.Style = a.Get("style").String()
+					s.Scene = a.Get("scene").String()
+					if s.Action == "popdoc" {
+						s.Scene = strings.ReplaceAll(s.Scene, "{{identifier}}", identifier)
+					}
+					_, dependency := shortcut_JS(s.Hotkey, gridscene, s.Action, s.Scene, s.Caption)
+					base.MergeDependency(&spec.Dependencies, dependency)
+					spec.Shortcuts = append(spec.Shortcuts, s)
+				}
+			}
+		} else if !isReservedWord(name) && v.Type.String() == "JSON" {
+			if property := v.Get("property").String(); len(name) > 0 && perm.CanSee(property) {
+				col := GridColumnT{
+					Name:       name,
+					Property:   property,
+					Caption:    base.LanguageLabel(v.Get("caption").String(), clientlanguage_code),
+					Query:      v.Get("query").String(),
+					Hyperlink:  v.Get("hyperlink").String(),
+					Mark:       v.Get("mark").String(),
+					Onclick:    v.Get("onclick").String(),
+					Order:      v.Get("order").String(),
+					Render:     v.Get("render").String(),
+					Align:      v.Get("text-align").String(),
+					Width:      v.Get("width").String(),
+					Transform:  v.Get("transform").String(),
+					Comparison: v.Get("comparison").String(),
+					Fixed:      v.Get("fixed").String(),
+					Visible:    v.Get("visible").String() != "false",
+				}
+				if actions := v.Get("actions"); actions.IsArray() {
+					for _, a := range actions.Array() {
+						if !perm.Allow(a.Get("action").String()) {
+							continue
+						}
+						action := GridActionT{
+							Action:    a.Get("action").String(),
+							Icon:      a.Get("icon").String(),
+							Scene:     a.Get("scene").String(),
+							Caption:   base.LanguageLabel(a.Get("caption").String(), clientlanguage_code),
+							Hint:      base.LanguageLabel(a.Get("hint").String(), clientlanguage_code),
+							Condition: a.Get("condition").String(),
+						}
+						addUnique(&refers, extractProperty(action.Condition))
+						_, dependency := action_JS(action.Scene, action.Action, action.Scene, action.Hint)
+						base.MergeDependency(&spec.Dependencies, dependency)
+						col.Actions = append(col.Actions, action)
+					}
+				}
+				spec.Columns = append(spec.Columns, col)
+			}
+		}
+		return true // keep iterating
+	})
+	for _, v := range refers {
+		flag := true
+		for _, col := range spec.Columns {
+			if v == col.Property {
+				flag = false
+				break
+			}
+		}
+		if flag && (v == "id" || perm.CanSee(v)) { //a condition on a property not visible is not sent
+			spec.References = append(spec.References, v)
+		}
+	}
+	return
+}
+
+// JSON: the spec serialized.
+func (spec *GridSpecT) JSON() (b []byte, e error) {
+	b, e = json.Marshal(spec)
+	return
+}
+
+// ColumnsT: the columns as ParseGrid returns them.
+func (spec *GridSpecT) ColumnsT() (columns []ColumnT) {
+	for _, c := range spec.Columns {
+		col := ColumnT{Name: c.Name, Property: c.Property, Query: c.Query, Caption: c.Caption, Hyperlink: c.Hyperlink,
+			Mark: c.Mark, Onclick: c.Onclick, Order: c.Order, Render: c.Render, Align: c.Align, Width: c.Width,
+			Transform: c.Transform, Comparison: c.Comparison, Visible: c.Visible, Fixed: c.Fixed}
+		for _, a := range c.Actions {
+			col.Actions = append(col.Actions, actionT{a.Icon, a.Action, a.Scene, a.Caption, a.Hint, a.Condition})
+		}
+		columns = append(columns, col)
+	}
+	return
+}
+
+// JS is the jQuery adapter: the shortcut json, the toolbar of hotkeys and the javascript of shortcuts and actions.
+func (spec *GridSpecT) JS(gridscene string) (shortcut_block, hot_block, shortcut_js, shortcut_case, action_js string) {
+	shortcuts := []shortcutT{}
+	for _, s := range spec.Shortcuts {
+		caption := s.Caption
+		if len(s.Icon) > 0 {
+			caption = base.Iconhtml(s.Icon) + caption
+		}
+		js, _ := shortcut_JS(s.Hotkey, gridscene, s.Action, s.Scene, caption)
+		shortcut_js += js
+		shortcuts = append(shortcuts, shortcutT{s.Icon, s.Action, s.Caption})
+		shortcut_case += `case '` + s.Action + `':` + s.Action + `();break;`
+		if s.Hotkey {
+			hot_block += strings.Repeat("&nbsp;", 3)
+			hot_block += `<i id="` + escAttr(gridscene+`_`+s.Action) + `_btn" class="toolbtn `
+			if strings.HasPrefix(s.Icon, "fa-") {
+				hot_block += `fa ` + escAttr(s.Icon) + ` fa-lg`
+			}
+			hot_block += `"`
+			if len(s.Style) > 0 {
+				hot_block += ` style="` + escStyle(s.Style) + `"`
+			}
+			hot_block += `></i>`
+		}
+	}
+	for _, col := range spec.Columns {
+		for _, action := range col.Actions {
+			js, _ := action_JS(action.Scene, action.Action, action.Scene, action.Hint)
+			action_js += js
+		}
+	}
+	sb, _ := json.Marshal(shortcuts)
+	shortcut_block = string(sb)
+	return
+}
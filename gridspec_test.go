@@ -0,0 +1,83 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const gridSample = `{"type":"grid","rows_per_page":15,"sort":"-id",
+	"_shortcut_":[{"icon":"fa-plus","action":"popform","scene":"article_new","caption":"en:New;zh:新建","hotkey":true,"style":"color:red"},
+		{"action":"popdoc","scene":"doc/{{identifier}}.md","caption":"Help"}],
+	"title":{"property":"title","caption":"en:Title","hyperlink":"/article?id=[id]","width":"40%","order":"asc","comparison":"like"},
+	"status":{"property":"status_id","caption":"Status","render":"decoder","text-align":"center","transform":"LF2BR"},
+	"secret":{"property":"user_id","visible":"false"},
+	"op":{"property":"","caption":"Operation","fixed":"right","actions":[
+		{"action":"remove","icon":"fa-trash","scene":"article_remove","hint":"Remove?","condition":"released=0&user_id=session@user_id"},
+		{"action":"release","icon":"fa-check","scene":"article_release","condition":"recommend.ordinalposition>1"}]}}`
+
+// TestParseGridBaseline: ParseGrid gives what it gave before GridSpecT, the strings byte for byte.
+func TestParseGridBaseline(t *testing.T) {
+	grid := gjson.Parse(gridSample)
+	rows_per_page, columns, shortcut_block, hot_block, shortcut_js, shortcut_case, action_js, reference_properties, dependencies := ParseGrid(&grid, "article", "ag", "en")
+	if rows_per_page != 15 {
+		t.Errorf("rows_per_page = %d", rows_per_page)
+	}
+	wantColumns := []ColumnT{
+		{Name: "title", Property: "title", Caption: "en:Title", Hyperlink: "/article?id=[id]", Order: "asc", Width: "40%", Comparison: "like", Visible: true},
+		{Name: "status", Property: "status_id", Caption: "Status", Render: "decoder", Align: "center", Transform: "LF2BR", Visible: true},
+		{Name: "secret", Property: "user_id"},
+		{Name: "op", Caption: "Operation", Visible: true, Fixed: "right", Actions: []actionT{
+			{Icon: "fa-trash", Action: "remove", Scene: "article_remove", Hint: "Remove?", Condition: "released=0&user_id=session@user_id"},
+			{Icon: "fa-check", Action: "release", Scene: "article_release", Condition: "recommend.ordinalposition>1"}}},
+	}
+	if !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("columns =\n%#v\nwant\n%#v", columns, wantColumns)
+	}
+	for _, tt := range []struct {
+		name, got, want string
+	}{
+		{"shortcut_block", shortcut_block, "[{\"icon\":\"fa-plus\",\"action\":\"popform\",\"label\":\"en:New;zh:新建\"},{\"icon\":\"\",\"action\":\"popdoc\",\"label\":\"Help\"}]"},
+		{"hot_block", hot_block, "&nbsp;&nbsp;&nbsp;<i id=\"ag_popform_btn\" class=\"toolbtn fa fa-plus fa-lg\" style=\"color:red\"></i>"},
+		{"shortcut_js", shortcut_js, "$('#ag_popform_btn').live('click',function(){\tpopform();});function popdoc(){\t$('body').Popdoc({\t\tscene:'doc/article.md',caption:'Help',i18n:page_i18n\t});}"},
+		{"shortcut_case", shortcut_case, "case 'popform':popform();break;case 'popdoc':popdoc();break;"},
+		{"action_js", action_js, "case 'remove':\t$('body').YesnoAlert({\t\tyesText:'{{.t_yes}}',noText:'{{.t_no}}',\t\tdoyes: function(id,action){\t\t\t$.ajaxSettings.async = false;\t\t\t$.getJSON('/instanceoperate',{eid:entity_id,iid:instance_id,sub:subentity,act:action},function(m){\t\t\t\tif(m.Code==\"100\"){\t\t\t\t\tafterRemove(instance_id);gridRefresh(grid_name);\t\t\t\t}else{alert(m.Msg);}\t\t\t});\t\t\t$.ajaxSettings.async = true;\t\t}}).show_alertpane('','{{.txt_removeornot}}['+instance_name+']?',action);break;case 'release':\t$('body').YesnoAlert({\t\tyesText:'{{.t_yes}}',noText:'{{.t_no}}',\t\tdoyes: function(id,action){\t\t\tsaveandrefreshrow(grid_name,entity_id,subentity,instance_id,{id:instance_id,status:1,time_released:'NOW'});\t\t}\t}).show_alertpane('','{{.txt_releaseornot}}'+'?',action);\tbreak;"},
+		{"reference_properties", strings.Join(reference_properties, ","), "id,released,recommend.ordinalposition"},
+		{"dependencies", strings.Join(dependencies, ","), ""},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s =\n%q\nwant\n%q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestGridSpecJSON(t *testing.T) {
+	tests := []struct {
+		grid, json string
+	}{
+		{gridSample, `{"rows_per_page":15,"sort":"-id",` +
+			`"columns":[{"name":"title","property":"title","caption":"en:Title","hyperlink":"/article?id=[id]","order":"asc","width":"40%","comparison":"like","visible":true},` +
+			`{"name":"status","property":"status_id","caption":"Status","render":"decoder","align":"center","transform":"LF2BR","visible":true},` +
+			`{"name":"secret","property":"user_id","caption":"","visible":false},` +
+			`{"name":"op","property":"","caption":"Operation","fixed":"right","visible":true,"actions":[` +
+			`{"action":"remove","icon":"fa-trash","scene":"article_remove","hint":"Remove?","condition":"released=0\u0026user_id=session@user_id"},` +
+			`{"action":"release","icon":"fa-check","scene":"article_release","condition":"recommend.ordinalposition\u003e1"}]}],` +
+			`"shortcuts":[{"action":"popform","icon":"fa-plus","scene":"article_new","caption":"en:New;zh:新建","hotkey":true,"style":"color:red"},` +
+			`{"action":"popdoc","scene":"doc/article.md","caption":"Help"}],` +
+			`"reference_properties":["id","released","recommend.ordinalposition"],"dependencies":[]}`},
+		{`{"type":"grid","title":{"property":"title"},"id":{"property":"id"}}`,
+			`{"rows_per_page":0,"columns":[{"name":"title","property":"title","caption":"","visible":true},{"name":"id","property":"id","caption":"","visible":true}],` +
+				`"shortcuts":[],"reference_properties":[],"dependencies":[]}`},
+		{`{"type":"list"}`, `{"rows_per_page":0,"columns":[],"shortcuts":[],"reference_properties":[],"dependencies":[]}`},
+	}
+	for _, tt := range tests {
+		grid := gjson.Parse(tt.grid)
+		spec := ParseGridSpec(&grid, "article", "ag", "en", nil)
+		b, e := spec.JSON()
+		if e != nil || string(b) != tt.json {
+			t.Errorf("JSON() = %v\n%s\nwant\n%s", e, b, tt.json)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package object
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// Installer creates the tables and indexes of a definition and registers it in entity and entity_codeset.
+type Installer struct {
+	DB      *sql.DB
+	DB_type int
+	Creator string
+}
+
+type InstallResultT struct {
+	Kind         string
+	Table        string
+	SQL          string
+	RowsAffected int64
+	Error        error
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func NewInstaller(db *sql.DB, db_type int, creator string) (ins *Installer) {
+	ins = &Installer{DB: db, DB_type: db_type, Creator: creator}
+	return
+}
+
+/*
+Install runs the statements of an extended definition (DefinitionExtend output) in one transaction.
+MySQL commits DDL implicitly, so there the statements run one by one and a failure undoes the finished ones by their Down.
+results has one entry per statement run, the failing one last.
+*/
+func (ins *Installer) Install(definition, identifier string) (results []InstallResultT, e error) {
+	result := gjson.Get(definition, identifier)
+	if !result.Exists() {
+		e = errors.New(identifier + " syntax error!")
+		return
+	}
+	plan, err := Definition2Plan(definition, identifier, ins.Creator, ins.DB_type, "", "")
+	if err != nil {
+		e = err
+		return
+	}
+	op := GetObjectProperty(result, identifier)
+	if ins.DB_type == base.MySQL {
+		results, e = ins.run(ins.DB, plan, op)
+		if e != nil {
+			done := SQLPlan{}
+			for i := 0; i < len(results)-1; i++ {
+				done = append(done, plan[i])
+			}
+			for _, dsql := range done.Down() {
+				ins.DB.Exec(dsql)
+			}
+		}
+	} else {
+		tx, err := ins.DB.Begin()
+		if err != nil {
+			e = err
+			return
+		}
+		results, e = ins.run(tx, plan, op)
+		if e == nil {
+			e = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+	return
+}
+
+// Uninstall drops what Install created, in reverse order.
+func (ins *Installer) Uninstall(definition, identifier string) (results []InstallResultT, e error) {
+	plan, err := Definition2Plan(definition, identifier, ins.Creator, ins.DB_type, "", "")
+	if err != nil {
+		e = err
+		return
+	}
+	for i := len(plan) - 1; i >= 0 && e == nil; i-- {
+		st := plan[i]
+		if len(st.Down) > 0 {
+			r := InstallResultT{Kind: st.Kind, Table: st.Table, SQL: st.Down}
+			r.RowsAffected, r.Error = execSQL(ins.DB, st.Down)
+			e = r.Error
+			results = append(results, r)
+		}
+	}
+	return
+}
+
+func (ins *Installer) run(db execer, plan SQLPlan, op ObjectpropertyT) (results []InstallResultT, e error) {
+	for _, st := range plan {
+		r := InstallResultT{Kind: st.Kind, Table: st.Table, SQL: st.Up}
+		switch st.Kind {
+		case "insert_entity":
+			query, args := InsertEntityStmt(op, ins.Creator, ins.DB_type)
+			r.SQL = query
+			r.RowsAffected, r.Error = execSQL(db, query, args...)
+		case "insert_codeset":
+			query, args := InsertCodesetStmt(op, ins.DB_type)
+			r.SQL = query
+			r.RowsAffected, r.Error = execSQL(db, query, args...)
+		default:
+			r.RowsAffected, r.Error = execSQL(db, st.Up)
+		}
+		results = append(results, r)
+		if r.Error != nil {
+			e = errors.New(st.Kind + " " + st.Table + ": " + r.Error.Error())
+			break
+		}
+	}
+	return
+}
+
+func execSQL(db execer, query string, args ...any) (affected int64, e error) {
+	var rs sql.Result
+	rs, e = db.Exec(query, args...)
+	if e == nil {
+		affected, _ = rs.RowsAffected()
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+const installDefinition = `{"book":{"type":"object","caption":"en:Book","comment":"books",
+	"id":{"type":"int"},"title":{"type":"string","size":50},"isbn":{"type":"string","size":20},
+	"chapter":{"type":"object","id":{"type":"int"},"book_id":{"type":"int"},"title":{"type":"string","size":50},
+		"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"book","properties":"book_id"}]},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"isbn","properties":"isbn"}]},
+"region":{"type":"codeset","coding_type":"hierarchical","code_structure":"2,2",
+	"id":{"type":"int"},"code":{"type":"string","size":10},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"}]}}`
+
+func TestInstaller(t *testing.T) {
+	db := openTestDB(t)
+	ins := NewInstaller(db, base.SQLite, "tester")
+	for _, identifier := range []string{"book", "region"} {
+		results, e := ins.Install(installDefinition, identifier)
+		if e != nil {
+			t.Fatalf("install %s: %v", identifier, e)
+		}
+		for _, r := range results {
+			if r.Error != nil {
+				t.Errorf("install %s: %s %s: %v", identifier, r.Kind, r.Table, r.Error)
+			}
+		}
+	}
+	tables, indexes := sqliteObjects(t, db, "table"), sqliteObjects(t, db, "index")
+	for _, name := range []string{"book", "book_chapter", "region"} {
+		if !tables[name] {
+			t.Errorf("table %s not created", name)
+		}
+	}
+	for _, name := range []string{"idx_book_isbn", "idx_book_chapter_book"} {
+		if !indexes[name] {
+			t.Errorf("index %s not created", name)
+		}
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity e JOIN metatype m ON m.id=e.metatype_id WHERE e.code=? AND m.code='object' AND e.creator='tester'", "book"); n != 1 {
+		t.Errorf("entity book: %d rows", n)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity_codeset c JOIN entity e ON e.id=c.id WHERE e.code=? AND c.coding_type=1 AND c.code_structure='2,2'", "region"); n != 1 {
+		t.Errorf("entity_codeset region: %d rows", n)
+	}
+
+	if _, e := ins.Install(installDefinition, "author"); e == nil {
+		t.Error("install author: no error")
+	}
+
+	for _, identifier := range []string{"region", "book"} {
+		if _, e := ins.Uninstall(installDefinition, identifier); e != nil {
+			t.Fatalf("uninstall %s: %v", identifier, e)
+		}
+	}
+	tables, indexes = sqliteObjects(t, db, "table"), sqliteObjects(t, db, "index")
+	for _, name := range []string{"book", "book_chapter", "region"} {
+		if tables[name] {
+			t.Errorf("table %s left after uninstall", name)
+		}
+	}
+	if len(indexes) > 0 {
+		t.Errorf("indexes left after uninstall: %v", indexes)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity"); n != 0 {
+		t.Errorf("entity: %d rows left after uninstall", n)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity_codeset"); n != 0 {
+		t.Errorf("entity_codeset: %d rows left after uninstall", n)
+	}
+}
+
+func TestInstallerRollback(t *testing.T) {
+	db := openTestDB(t)
+	if _, e := db.Exec("DROP TABLE entity_codeset"); e != nil { //insert_codeset fails after everything else ran
+		t.Fatal(e)
+	}
+	ins := NewInstaller(db, base.SQLite, "tester")
+	results, e := ins.Install(installDefinition, "region")
+	if e == nil {
+		t.Fatal("install region: no error")
+	}
+	if len(results) == 0 || results[len(results)-1].Kind != "insert_codeset" || results[len(results)-1].Error == nil {
+		t.Errorf("results do not end with the failing insert_codeset: %+v", results)
+	}
+	if sqliteObjects(t, db, "table")["region"] {
+		t.Error("table region left after a failed install")
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity"); n != 0 {
+		t.Errorf("entity: %d rows left after a failed install", n)
+	}
+
+	if _, e = db.Exec("CREATE TABLE book_chapter(id INTEGER)"); e != nil { //create_table book_chapter fails
+		t.Fatal(e)
+	}
+	if _, e = ins.Install(installDefinition, "book"); e == nil {
+		t.Fatal("install book: no error")
+	}
+	if tables := sqliteObjects(t, db, "table"); tables["book"] {
+		t.Error("table book left after a failed install")
+	}
+	if indexes := sqliteObjects(t, db, "index"); indexes["idx_book_isbn"] {
+		t.Error("index idx_book_isbn left after a failed install")
+	}
+}
@@ -0,0 +1,191 @@
+package object
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+)
+
+// languageTable: the <tablename>_languages table and the language_adaptive columns it carries.
+func (repo *Repository) languageTable(tablename string) (rt, lt *repoTableT, adaptive []string, e error) {
+	if rt, e = repo.table(tablename); e != nil {
+		return
+	}
+	lt, ok := repo.tables[tablename+"_languages"]
+	if !ok {
+		e = errors.New(tablename + " is not multi-language!")
+		return
+	}
+	for _, c := range rt.columns {
+		if rt.mapV[c].Get("language_adaptive").Bool() && lt.hasColumn(c) {
+			adaptive = append(adaptive, c)
+		}
+	}
+	return
+}
+
+// translations: the rows of the languages table for id by language_id.
+func (repo *Repository) translations(db queryer, rt, lt *repoTableT, id int64) (mapL map[string]RowT, e error) {
+	query := "SELECT * FROM " + repo.ident(lt.name) + " WHERE " + repo.ident(rt.name+"_id") + "=" + repo.placeholder(1) + " ORDER BY id"
+	rows, err := repo.query(db, lt, query, id)
+	if err != nil {
+		e = err
+		return
+	}
+	mapL = make(map[string]RowT)
+	for _, row := range rows {
+		language_id := strconv.FormatInt(toInt64(row["language_id"]), 10)
+		if _, ok := mapL[language_id]; !ok {
+			mapL[language_id] = row
+		}
+	}
+	return
+}
+
+func emptyValue(v any) bool {
+	switch s := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(s) == 0
+	case []byte:
+		return len(s) == 0
+	}
+	return false
+}
+
+/*
+GetInLanguage reads the row of tablename by id with its language_adaptive properties in language_id,
+a property not translated falls back to the base language, then to the value of the row itself.
+*/
+func (repo *Repository) GetInLanguage(tablename string, id int64, language_id string) (row RowT, e error) {
+	rt, lt, adaptive, err := repo.languageTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	if row, e = repo.Get(tablename, id); e != nil {
+		return
+	}
+	mapL, err := repo.translations(repo.DB, rt, lt, id)
+	if err != nil {
+		e = err
+		return
+	}
+	for _, c := range adaptive {
+		for _, lid := range []string{language_id, base.BaseLanguage_id()} {
+			if tr, ok := mapL[lid]; ok && !emptyValue(tr[c]) {
+				row[c] = tr[c]
+				break
+			}
+		}
+	}
+	return
+}
+
+/*
+SetTranslation writes the language_adaptive properties in values for language_id of the row of tablename by id.
+The base language is written to the row itself as well, it is what reads without a language see.
+*/
+func (repo *Repository) SetTranslation(tablename string, id int64, language_id string, values RowT) (e error) {
+	rt, lt, adaptive, err := repo.languageTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	for _, k := range sortedKeys(values) {
+		if exists, _ := base.In_array(k, adaptive); !exists {
+			e = errors.New(tablename + "." + k + " is not language adaptive!")
+			return
+		}
+	}
+	e = repo.inTx(func(tx *sql.Tx) error {
+		return repo.setTranslation(tx, rt, lt, id, language_id, values)
+	})
+	return
+}
+
+func (repo *Repository) setTranslation(db queryer, rt, lt *repoTableT, id int64, language_id string, values RowT) (e error) {
+	language_tag := ""
+	for _, l := range base.AcceptLanguages(1) {
+		if l.Language_id == language_id {
+			language_tag = l.Language_tag
+		}
+	}
+	row, err := repo.query(db, rt, "SELECT * FROM "+repo.ident(rt.name)+" WHERE id="+repo.placeholder(1), id)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(row) == 0 {
+		e = sql.ErrNoRows
+		return
+	}
+	mapL, err := repo.translations(db, rt, lt, id)
+	if err != nil {
+		e = err
+		return
+	}
+	tr := RowT{}
+	for k, v := range values {
+		tr[k] = v
+	}
+	if old, ok := mapL[language_id]; ok {
+		_, e = repo.update(db, lt, toInt64(old["id"]), tr)
+	} else {
+		for _, c := range lt.columns { //<ancestor>_id columns, <tablename>_id included
+			if v, ok := row[0][c]; ok && strings.HasSuffix(c, "_id") && c != "language_id" {
+				tr[c] = v
+			}
+		}
+		tr[rt.name+"_id"] = id
+		tr["language_id"] = base.Str2int(language_id)
+		tr["language_tag"] = language_tag
+		_, e = repo.insert(db, lt, tr)
+	}
+	if e == nil && language_id == base.BaseLanguage_id() {
+		_, e = repo.update(db, rt, id, values)
+	}
+	return
+}
+
+/*
+MissingLanguages lists per row of ids the accepted languages lacking a translation of some language_adaptive property
+which the row itself has. The row itself stands for the base language, which is never missing.
+*/
+func (repo *Repository) MissingLanguages(tablename string, ids ...int64) (missing map[int64][]string, e error) {
+	rt, lt, adaptive, err := repo.languageTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	missing = make(map[int64][]string)
+	for _, id := range ids {
+		row, err := repo.Get(tablename, id)
+		if err != nil {
+			e = err
+			return
+		}
+		mapL, err := repo.translations(repo.DB, rt, lt, id)
+		if err != nil {
+			e = err
+			return
+		}
+		for _, l := range base.AcceptLanguages(1) {
+			if l.Language_id == base.BaseLanguage_id() {
+				continue
+			}
+			tr, ok := mapL[l.Language_id]
+			for _, c := range adaptive {
+				if !emptyValue(row[c]) && (!ok || emptyValue(tr[c])) {
+					missing[id] = append(missing[id], l.Language_id)
+					break
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+func TestLanguage(t *testing.T) {
+	repo := openTestRepository(t, repositoryDefinition, "category")
+	c1 := mustInsert(t, repo, "category", RowT{"name": "row", "note": "n"})
+	c2 := mustInsert(t, repo, "category", RowT{"name": "two"})
+	c3 := mustInsert(t, repo, "category", RowT{"note": "no name"})
+	getName := func(id int64, language_id string) any {
+		t.Helper()
+		row, e := repo.GetInLanguage("category", id, language_id)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return row["name"]
+	}
+
+	if got := getName(c1, "2"); got != "row" {
+		t.Errorf("untranslated: %v, want the row itself", got)
+	}
+	if e := repo.SetTranslation("category", c1, base.BaseLanguage_id(), RowT{"name": "base"}); e != nil {
+		t.Fatal(e)
+	}
+	if row, e := repo.Get("category", c1); e != nil || row["name"] != "base" || row["note"] != "n" {
+		t.Errorf("row after a base language write = %v, %v", row, e)
+	}
+	if got := getName(c1, "2"); got != "base" {
+		t.Errorf("no zh translation: %v, want the base language", got)
+	}
+	if e := repo.SetTranslation("category", c1, "2", RowT{"name": "zh"}); e != nil {
+		t.Fatal(e)
+	}
+	if e := repo.SetTranslation("category", c1, "2", RowT{"name": "zh2"}); e != nil {
+		t.Fatal(e)
+	}
+	if got := getName(c1, "2"); got != "zh2" {
+		t.Errorf("zh: %v, want zh2", got)
+	}
+	if got := getName(c1, base.BaseLanguage_id()); got != "base" {
+		t.Errorf("base: %v, want base", got)
+	}
+	if row, e := repo.Get("category", c1); e != nil || row["name"] != "base" {
+		t.Errorf("row after a zh write = %v, %v", row, e)
+	}
+	if n, e := repo.Count("category_languages", RowT{"category_id": c1}); e != nil || n != 2 {
+		t.Errorf("translations of c1 = %d, %v, want 2", n, e)
+	}
+
+	missing, e := repo.MissingLanguages("category", c1, c2, c3)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if want := map[int64][]string{c2: {"2"}}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("MissingLanguages = %v, want %v", missing, want)
+	}
+	if e = repo.SetTranslation("category", c2, "2", RowT{"name": ""}); e != nil {
+		t.Fatal(e)
+	}
+	if missing, e = repo.MissingLanguages("category", c2); e != nil || len(missing[c2]) != 1 {
+		t.Errorf("empty translation: MissingLanguages = %v, %v", missing, e)
+	}
+
+	if e = repo.SetTranslation("category", c1, "2", RowT{"note": "x"}); e == nil {
+		t.Error("translation of a property not language adaptive: no error")
+	}
+	if e = repo.SetTranslation("category", 99, "2", RowT{"name": "x"}); e == nil {
+		t.Error("translation of a missing row: no error")
+	}
+	if _, e = repo.GetInLanguage("category_item", c1, "2"); e == nil {
+		t.Error("GetInLanguage of a single language table: no error")
+	}
+}
@@ -0,0 +1,313 @@
+package object
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+MigrationStep is one change of a migration plan.
+Kind: create_table, drop_table, add_column, drop_column, rename_column, alter_column, create_index, drop_index, rebuild_table.
+SQL is empty for a column change of SQLite, the rebuild_table step following it carries the statements.
+Destructive steps may lose data and should be reviewed before they run.
+*/
+type MigrationStep struct {
+	Kind        string
+	Table       string
+	Name        string
+	SQL         []string
+	Destructive bool
+}
+
+type MigrationPlan struct {
+	Steps []MigrationStep
+}
+
+func (mp *MigrationPlan) SQL() (sqlsql []string) {
+	for _, step := range mp.Steps {
+		sqlsql = append(sqlsql, step.SQL...)
+	}
+	return
+}
+
+func (mp *MigrationPlan) Destructive() (steps []MigrationStep) {
+	for _, step := range mp.Steps {
+		if step.Destructive {
+			steps = append(steps, step)
+		}
+	}
+	return
+}
+
+// tableStateT is the current state of a table, from the previous definition or from the live table.
+type tableStateT struct {
+	fields  []string
+	exists  func(field_name string) bool
+	same    func(old_name, field_name string, v gjson.Result) bool
+	retyped func(old_name string, v gjson.Result) bool
+	indexes map[string]string //index name: CREATE INDEX, nil when unknown
+}
+
+type tableT struct {
+	roadmap []string
+	o       gjson.Result
+}
+
+func collectTables(o gjson.Result, roadmap []string, tables *[]tableT) {
+	o_type := o.Get("type").String()
+	if strings.HasPrefix(o_type, "object") || o_type == "codeset" {
+		*tables = append(*tables, tableT{roadmap, o})
+		o.ForEach(func(k, v gjson.Result) bool {
+			if v.IsObject() && strings.HasPrefix(v.Get("type").String(), "object") {
+				collectTables(v, append(append([]string{}, roadmap...), k.String()), tables)
+			}
+			return true
+		})
+	}
+}
+
+func tableColumns(o gjson.Result) (columns []string, mapV map[string]gjson.Result) {
+	mapV = make(map[string]gjson.Result)
+	o.ForEach(func(k, v gjson.Result) bool {
+		field_name := k.String()
+		if v.Type.String() == "JSON" && field_name != "indexes" && !strings.HasPrefix(v.Get("type").String(), "object") {
+			columns = append(columns, field_name)
+			mapV[field_name] = v
+		}
+		return true
+	})
+	return
+}
+
+type indexSQLT struct {
+	name   string
+	create string //CREATE INDEX
+}
+
+// indexSQLs: the CREATE INDEX statements in declaration order, unnamed and same named indexes kept.
+func indexSQLs(o gjson.Result, tablename string, db_type int) (idxes []indexSQLT) {
+	for _, v := range o.Get("indexes").Array() {
+		ii, _, _ := createIndexSQL(gjson.Parse(`{"indexes":[`+v.Raw+`]}`), tablename, db_type)
+		if len(ii) > 0 {
+			idxes = append(idxes, indexSQLT{v.Get("name").String(), ii[0]})
+		}
+	}
+	return
+}
+
+// indexMap: CREATE INDEX by index name.
+func indexMap(idxes []indexSQLT) (mapIdx map[string]string) {
+	mapIdx = make(map[string]string)
+	for _, idx := range idxes {
+		mapIdx[idx.name] = idx.create
+	}
+	return
+}
+
+func dropIndexSQL(tablename, index_name string, db_type int) (dsql string) {
+	dsql = "DROP INDEX " + indexName(tablename, index_name, db_type)
+	if db_type == base.MySQL {
+		dsql += " ON " + sqlIdentifier(tablename, db_type)
+	}
+	return
+}
+
+func createTableSteps(o gjson.Result, roadmap []string, db_type int) (step MigrationStep) {
+	tablename := strings.Join(roadmap, "_")
+	idxes, _, primary := createIndexSQL(o, tablename, db_type)
+	step = MigrationStep{Kind: "create_table", Table: tablename, Name: tablename}
+	step.SQL = append(step.SQL, CreateTableSQL(o, roadmap, primary, "", db_type, "", ""))
+	step.SQL = append(step.SQL, CreateCommentSQL(o, roadmap, db_type)...)
+	step.SQL = append(step.SQL, idxes...)
+	return
+}
+
+/*
+PlanMigration compares two extended definitions (DefinitionExtend output) of identifier, sub-objects included.
+A property renamed in the new definition names its former property by "renamed_from".
+*/
+func PlanMigration(from, to, identifier string, db_type int) (plan MigrationPlan, e error) {
+	o_from, o_to := gjson.Get(from, identifier), gjson.Get(to, identifier)
+	if !o_from.Exists() || !o_to.Exists() {
+		e = errors.New(identifier + " syntax error!")
+		return
+	}
+	tables_from, tables_to := []tableT{}, []tableT{}
+	collectTables(o_from, []string{identifier}, &tables_from)
+	collectTables(o_to, []string{identifier}, &tables_to)
+	mapFrom := make(map[string]gjson.Result)
+	for _, t := range tables_from {
+		mapFrom[strings.Join(t.roadmap, "_")] = t.o
+	}
+	mapTo := make(map[string]bool)
+	for _, t := range tables_to {
+		tablename := strings.Join(t.roadmap, "_")
+		mapTo[tablename] = true
+		if of, ok := mapFrom[tablename]; ok {
+			plan.Steps = append(plan.Steps, planTable(t.o, t.roadmap, definitionState(of, tablename, db_type), db_type)...)
+		} else {
+			plan.Steps = append(plan.Steps, createTableSteps(t.o, t.roadmap, db_type))
+		}
+	}
+	for i := len(tables_from) - 1; i >= 0; i-- { //sub-objects first
+		tablename := strings.Join(tables_from[i].roadmap, "_")
+		if !mapTo[tablename] {
+			plan.Steps = append(plan.Steps, MigrationStep{"drop_table", tablename, tablename, []string{"DROP TABLE " + sqlIdentifier(tablename, db_type)}, true})
+		}
+	}
+	return
+}
+
+/*
+PlanLiveMigration compares an extended object with its live table read by ti.ReadFields, like UpdateTableSQL
+but with drops, renames and SQLite rebuilds. The live indexes are unknown so index steps are not planned.
+A change is destructive when the column type differs from the live one, not for a default or nullability change.
+*/
+func PlanLiveMigration(o gjson.Result, roadmap []string, ti *base.TableInfoT, db_type int) (plan MigrationPlan) {
+	tablename := strings.Join(roadmap, "_")
+	objecttype := o.Get("type").String()
+	_, _, primary := createIndexSQL(o, tablename, db_type)
+	var ts tableStateT
+	ts.fields = sortedKeys(ti.Fields)
+	ts.exists = ti.FieldExists
+	ts.same = func(old_name, field_name string, v gjson.Result) bool {
+		return ti.SameProperty(old_name, property2SQL(objecttype, v, db_type, old_name, primary, false))
+	}
+	ts.retyped = func(old_name string, v gjson.Result) bool {
+		return !strings.EqualFold(ti.Fields[old_name].Type, columnType(v, db_type))
+	}
+	plan.Steps = planTable(o, roadmap, ts, db_type)
+	return
+}
+
+func definitionState(of gjson.Result, tablename string, db_type int) (ts tableStateT) {
+	objecttype := of.Get("type").String()
+	_, _, primary := createIndexSQL(of, tablename, db_type)
+	columns, mapV := tableColumns(of)
+	ts.fields = columns
+	ts.exists = func(field_name string) bool {
+		_, ok := mapV[field_name]
+		return ok
+	}
+	ts.same = func(old_name, field_name string, v gjson.Result) bool {
+		return property2SQL(objecttype, mapV[old_name], db_type, field_name, primary, false) == property2SQL(objecttype, v, db_type, field_name, primary, false)
+	}
+	ts.retyped = func(old_name string, v gjson.Result) bool {
+		return columnType(mapV[old_name], db_type) != columnType(v, db_type)
+	}
+	ts.indexes = indexMap(indexSQLs(of, tablename, db_type))
+	return
+}
+
+func planTable(o gjson.Result, roadmap []string, ts tableStateT, db_type int) (steps []MigrationStep) {
+	tablename := strings.Join(roadmap, "_")
+	table := sqlIdentifier(tablename, db_type)
+	objecttype := o.Get("type").String()
+	_, _, primary := createIndexSQL(o, tablename, db_type)
+	columns, mapV := tableColumns(o)
+	rebuild, destructive := false, false
+	copyfrom := make(map[string]string) //new column: old column
+	renamed := []string{}
+	for _, field_name := range columns {
+		v := mapV[field_name]
+		normal_propertySQL := property2SQL(objecttype, v, db_type, field_name, primary, true)
+		field := sqlIdentifier(field_name, db_type)
+		old_name := v.Get("renamed_from").String()
+		if ts.exists(field_name) {
+			copyfrom[field_name] = field_name
+			if !ts.same(field_name, field_name, v) {
+				step := MigrationStep{Kind: "alter_column", Table: tablename, Name: field_name, Destructive: ts.retyped(field_name, v)}
+				if db_type == base.SQLite {
+					rebuild = true
+				} else if asql := alterColumnSQL(v, db_type, field_name, primary, normal_propertySQL); len(asql) > 0 {
+					step.SQL = []string{"alter table " + table + asql}
+				}
+				destructive = destructive || step.Destructive
+				steps = append(steps, step)
+			}
+		} else if len(old_name) > 0 && ts.exists(old_name) {
+			copyfrom[field_name] = old_name
+			renamed = append(renamed, old_name)
+			step := MigrationStep{Kind: "rename_column", Table: tablename, Name: old_name + "," + field_name, Destructive: ts.retyped(old_name, v)}
+			switch db_type {
+			case base.SQLite:
+				rebuild = true
+			case base.MySQL:
+				step.SQL = []string{"alter table " + table + " change " + sqlIdentifier(old_name, db_type) + " " + normal_propertySQL}
+			case PostgreSQL:
+				step.SQL = []string{"alter table " + table + " RENAME COLUMN " + sqlIdentifier(old_name, db_type) + " TO " + field}
+				if !ts.same(old_name, field_name, v) {
+					if asql := alterColumnSQL(v, db_type, field_name, primary, normal_propertySQL); len(asql) > 0 {
+						step.SQL = append(step.SQL, "alter table "+table+asql)
+					}
+				}
+			}
+			destructive = destructive || step.Destructive
+			steps = append(steps, step)
+		} else {
+			asql := " ADD COLUMN "
+			if db_type == base.MySQL {
+				asql = " add "
+			}
+			steps = append(steps, MigrationStep{Kind: "add_column", Table: tablename, Name: field_name, SQL: []string{"alter table " + table + asql + normal_propertySQL}})
+		}
+	}
+	for _, field_name := range ts.fields {
+		if _, ok := mapV[field_name]; ok {
+			continue
+		}
+		if exists, _ := base.In_array(field_name, renamed); exists {
+			continue
+		}
+		step := MigrationStep{Kind: "drop_column", Table: tablename, Name: field_name, Destructive: true}
+		if db_type == base.SQLite {
+			rebuild = true
+		} else {
+			step.SQL = []string{"alter table " + table + " DROP COLUMN " + sqlIdentifier(field_name, db_type)}
+		}
+		destructive = true
+		steps = append(steps, step)
+	}
+	if rebuild { //SQLite: create a new table, copy the rows, swap it in
+		for i := range steps {
+			steps[i].SQL = nil
+		}
+		tmp := "_" + tablename
+		step := MigrationStep{Kind: "rebuild_table", Table: tablename, Name: tablename, Destructive: destructive}
+		step.SQL = append(step.SQL, CreateTableSQL(o, []string{tmp}, primary, "", db_type, "", ""))
+		into, from := []string{}, []string{}
+		for _, field_name := range columns {
+			if old_name, ok := copyfrom[field_name]; ok {
+				into = append(into, sqlIdentifier(field_name, db_type))
+				from = append(from, sqlIdentifier(old_name, db_type))
+			}
+		}
+		if len(into) > 0 {
+			step.SQL = append(step.SQL, "INSERT INTO "+sqlIdentifier(tmp, db_type)+"("+strings.Join(into, ",")+") SELECT "+strings.Join(from, ",")+" FROM "+table)
+		}
+		step.SQL = append(step.SQL, "DROP TABLE "+table)
+		step.SQL = append(step.SQL, "ALTER TABLE "+sqlIdentifier(tmp, db_type)+" RENAME TO "+table)
+		idxes, _, _ := createIndexSQL(o, tablename, db_type)
+		step.SQL = append(step.SQL, idxes...)
+		steps = append(steps, step)
+	} else if ts.indexes != nil { //drop indexes first, DROP COLUMN drops the indexes on the column of MySQL and PostgreSQL
+		idxes := indexSQLs(o, tablename, db_type)
+		mapIdx := indexMap(idxes)
+		drops := []MigrationStep{}
+		for _, name := range sortedKeys(ts.indexes) {
+			if mapIdx[name] != ts.indexes[name] {
+				drops = append(drops, MigrationStep{Kind: "drop_index", Table: tablename, Name: name, SQL: []string{dropIndexSQL(tablename, name, db_type)}})
+			}
+		}
+		steps = append(drops, steps...)
+		for _, idx := range idxes {
+			if ts.indexes[idx.name] != idx.create {
+				steps = append(steps, MigrationStep{Kind: "create_index", Table: tablename, Name: idx.name, SQL: []string{idx.create}})
+			}
+		}
+	}
+	return
+}
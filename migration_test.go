@@ -0,0 +1,194 @@
+package object
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+const migrationFrom = `{"book":{"type":"object",
+	"id":{"type":"int"},"title":{"type":"string","size":50},"isbn":{"type":"string","size":20},
+	"pages":{"type":"int"},"summary":{"type":"string","size":200},"note":{"type":"string","size":10},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"title","properties":"title"}]}}`
+
+const migrationTo = `{"book":{"type":"object",
+	"id":{"type":"int"},"name":{"type":"string","size":50,"renamed_from":"title"},"isbn":{"type":"string","size":20},
+	"pages":{"type":"long","default":"1"},"summary":{"type":"text"},"price":{"type":"float"},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"isbn","properties":"isbn"}]}}`
+
+func TestPlanMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		db_type int
+		kinds   []string
+		sql     []string
+	}{
+		{"sqlite", base.SQLite,
+			[]string{"rename_column", "alter_column", "alter_column", "add_column", "drop_column", "rebuild_table"},
+			[]string{
+				"CREATE TABLE `_book`(`id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,`name` varchar(50) DEFAULT '',`isbn` varchar(20) DEFAULT '',`pages` bigint DEFAULT '1',`summary` TEXT,`price` REAL DEFAULT '0')",
+				"INSERT INTO `_book`(`id`,`name`,`isbn`,`pages`,`summary`) SELECT `id`,`title`,`isbn`,`pages`,`summary` FROM `book`",
+				"DROP TABLE `book`",
+				"ALTER TABLE `_book` RENAME TO `book`",
+				"CREATE INDEX `idx_book_isbn` ON `book`(`isbn`)",
+			}},
+		{"mysql", base.MySQL,
+			[]string{"drop_index", "rename_column", "alter_column", "alter_column", "add_column", "drop_column", "create_index"},
+			[]string{
+				"DROP INDEX `idx_book_title` ON `book`",
+				"alter table `book` change `title` `name` varchar(50) DEFAULT ''",
+				"alter table `book` modify `pages` bigint DEFAULT '1'",
+				"alter table `book` modify `summary` TEXT",
+				"alter table `book` add `price` FLOAT DEFAULT '0'",
+				"alter table `book` DROP COLUMN `note`",
+				"CREATE INDEX `idx_book_isbn` ON `book`(`isbn`)",
+			}},
+		{"postgresql", PostgreSQL,
+			[]string{"drop_index", "rename_column", "alter_column", "alter_column", "add_column", "drop_column", "create_index"},
+			[]string{
+				`DROP INDEX "idx_book_title"`,
+				`alter table "book" RENAME COLUMN "title" TO "name"`,
+				`alter table "book" ALTER COLUMN "pages" TYPE bigint USING "pages"::bigint, ALTER COLUMN "pages" SET DEFAULT '1', ALTER COLUMN "pages" DROP NOT NULL`,
+				`alter table "book" ALTER COLUMN "summary" TYPE TEXT USING "summary"::TEXT, ALTER COLUMN "summary" DROP DEFAULT, ALTER COLUMN "summary" DROP NOT NULL`,
+				`alter table "book" ADD COLUMN "price" REAL DEFAULT '0'`,
+				`alter table "book" DROP COLUMN "note"`,
+				`CREATE INDEX "idx_book_isbn" ON "book"("isbn")`,
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, e := PlanMigration(migrationFrom, migrationTo, "book", tt.db_type)
+			if e != nil {
+				t.Fatal(e)
+			}
+			kinds := []string{}
+			for _, step := range plan.Steps {
+				kinds = append(kinds, step.Kind)
+				if tt.db_type == base.SQLite && step.Kind != "rebuild_table" && len(step.SQL) > 0 {
+					t.Errorf("%s %s: SQL %q, the rebuild carries it", step.Kind, step.Name, step.SQL)
+				}
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Errorf("kinds = %q, want %q", kinds, tt.kinds)
+			}
+			if sql := plan.SQL(); !reflect.DeepEqual(sql, tt.sql) {
+				t.Errorf("SQL =\n%s\nwant\n%s", strings.Join(sql, "\n"), strings.Join(tt.sql, "\n"))
+			}
+			destructive := []string{}
+			for _, step := range plan.Destructive() {
+				destructive = append(destructive, step.Kind+" "+step.Name)
+			}
+			want := []string{"alter_column pages", "alter_column summary", "drop_column note"}
+			if tt.db_type == base.SQLite {
+				want = append(want, "rebuild_table book")
+			}
+			if !reflect.DeepEqual(destructive, want) {
+				t.Errorf("destructive = %q, want %q", destructive, want)
+			}
+		})
+	}
+}
+
+func TestPlanMigrationDropIndexedColumn(t *testing.T) {
+	from := `{"book":{"type":"object","id":{"type":"int"},"isbn":{"type":"string","size":20},"note":{"type":"string","size":10},
+		"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"isbn","properties":"isbn,note"},{"name":"note","properties":"note"}]}}`
+	to := `{"book":{"type":"object","id":{"type":"int"},"isbn":{"type":"string","size":20},
+		"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"isbn","properties":"isbn"}]}}`
+	for _, tt := range []struct {
+		db_type int
+		sql     []string
+	}{
+		{base.MySQL, []string{
+			"DROP INDEX `idx_book_isbn` ON `book`",
+			"DROP INDEX `idx_book_note` ON `book`",
+			"alter table `book` DROP COLUMN `note`",
+			"CREATE INDEX `idx_book_isbn` ON `book`(`isbn`)",
+		}},
+		{PostgreSQL, []string{
+			`DROP INDEX "idx_book_isbn"`,
+			`DROP INDEX "idx_book_note"`,
+			`alter table "book" DROP COLUMN "note"`,
+			`CREATE INDEX "idx_book_isbn" ON "book"("isbn")`,
+		}},
+	} {
+		plan, e := PlanMigration(from, to, "book", tt.db_type)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if sql := plan.SQL(); !reflect.DeepEqual(sql, tt.sql) {
+			t.Errorf("%d: SQL =\n%s\nwant\n%s", tt.db_type, strings.Join(sql, "\n"), strings.Join(tt.sql, "\n"))
+		}
+	}
+}
+
+func TestPlanMigrationTables(t *testing.T) {
+	from := `{"book":{"type":"object","id":{"type":"int"},
+		"chapter":{"type":"object","id":{"type":"int"},"title":{"type":"string","size":50}}}}`
+	to := `{"book":{"type":"object","id":{"type":"int"},
+		"review":{"type":"object","id":{"type":"int"},"stars":{"type":"int"}}}}`
+	plan, e := PlanMigration(from, to, "book", base.MySQL)
+	if e != nil {
+		t.Fatal(e)
+	}
+	got := []string{}
+	for _, step := range plan.Steps {
+		got = append(got, step.Kind+" "+step.Name)
+	}
+	want := []string{"create_table book_review", "drop_table book_chapter"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+	if _, e = PlanMigration(from, to, "author", base.MySQL); e == nil {
+		t.Error("missing identifier: no error")
+	}
+}
+
+func TestPlanLiveMigration(t *testing.T) {
+	o := gjson.Get(migrationTo, "book")
+	for _, db_type := range []int{base.SQLite, base.MySQL, PostgreSQL} {
+		var ti base.TableInfoT //no live column
+		plan := PlanLiveMigration(o, []string{"book"}, &ti, db_type)
+		got := []string{}
+		for _, step := range plan.Steps {
+			got = append(got, step.Kind+" "+step.Name)
+			if len(step.SQL) != 1 {
+				t.Errorf("%d %s %s: SQL %q", db_type, step.Kind, step.Name, step.SQL)
+			}
+		}
+		want := []string{"add_column id", "add_column name", "add_column isbn", "add_column pages", "add_column summary", "add_column price"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: steps = %q, want %q", db_type, got, want)
+		}
+	}
+}
+
+func TestPlanLiveMigrationDestructive(t *testing.T) {
+	o := gjson.Get(migrationTo, "book")
+	ti := base.TableInfoT{Fields: map[string]base.FieldInfoT{
+		"id":      {Name: "id", Type: "int"},
+		"title":   {Name: "title", Type: "varchar(50)"},
+		"isbn":    {Name: "isbn", Type: "VARCHAR(20)"},
+		"pages":   {Name: "pages", Type: "bigint"},
+		"summary": {Name: "summary", Type: "varchar(200)"},
+	}}
+	plan := PlanLiveMigration(o, []string{"book"}, &ti, base.MySQL)
+	destructive := []string{}
+	for _, step := range plan.Destructive() {
+		destructive = append(destructive, step.Kind+" "+step.Name)
+	}
+	if want := []string{"alter_column summary"}; !reflect.DeepEqual(destructive, want) { //pages changes its default only
+		t.Errorf("destructive = %q, want %q", destructive, want)
+	}
+}
+
+func TestAlterColumnSQLPrimary(t *testing.T) {
+	v := gjson.Parse(`{"type":"int"}`)
+	got := alterColumnSQL(v, PostgreSQL, "id", `"id"`, "")
+	want := ` ALTER COLUMN "id" TYPE INTEGER USING "id"::INTEGER, ALTER COLUMN "id" SET NOT NULL`
+	if got != want {
+		t.Errorf("alterColumnSQL = %q, want %q", got, want)
+	}
+}
@@ -1,7 +1,6 @@
 package object
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -22,6 +21,10 @@ const (
 	CRLF = "\r\n"
 )
 
+const (
+	PostgreSQL = 5432 //db_type of PostgreSQL, base only knows SQLite and MySQL
+)
+
 type indexT struct {
 	idx_type       string
 	idx_properties string
@@ -125,6 +128,7 @@ func mapKV_full(i_type, i_options, i_default, i_comment, i_caption, i_pattern, i
 	return
 }
 
+// UserAccess returns the raw "access" SQL of the first limit entry for user_type, see UserAccessWhere for parameterized rules.
 func UserAccess(user_type int, limit gjson.Result) (access string) {
 	//fmt.Println("UserAccess:", limit.String())
 	//fmt.Println("UserType:", user_type, base.GetUsertype(user_type))
@@ -169,7 +173,7 @@ func textProperty(id, EMPHASIS string, mpKV map[string]string) (txt, txtread str
 					mm = append(mm, quote("size")+": "+o_size)
 				}
 			}
-			keys := []string{"options", "capacity", "unitofmeasure", "set_exclusive", "decimal_places", "encoding", "labeling", "default", "comment", "caption", "pattern", "index", "language_adaptive", "joinsuperiors"} //====****
+			keys := []string{"options", "capacity", "unitofmeasure", "set_exclusive", "decimal_places", "encoding", "labeling", "default", "comment", "caption", "pattern", "index", "language_adaptive", "joinsuperiors", "renamed_from"} //====****
 			n := len(keys)
 			for i := 0; i < n; i++ {
 				key := keys[i]
@@ -536,6 +540,34 @@ func sqlQuote(ss string) (tt string) {
 	return
 }
 
+func pgEscape(ss string) (tt string) {
+	tt = "'" + strings.ReplaceAll(ss, "'", "''") + "'"
+	return
+}
+
+// sqlString: ss as a string literal of db_type.
+func sqlString(ss string, db_type int) (tt string) {
+	switch db_type {
+	case base.MySQL:
+		tt = base.MySQLEscape(ss)
+	case PostgreSQL:
+		tt = pgEscape(ss)
+	default:
+		tt = base.SQLiteEscape(ss)
+	}
+	return
+}
+
+// sqlIdentifier quotes table, column and index names: `name` for SQLite/MySQL, "name" for PostgreSQL.
+func sqlIdentifier(name string, db_type int) (tt string) {
+	if db_type == PostgreSQL {
+		tt = `"` + name + `"`
+	} else {
+		tt = "`" + name + "`"
+	}
+	return
+}
+
 type ObjectpropertyT struct {
 	object_type    string
 	identifier     string
@@ -603,6 +635,8 @@ func InsertEntitySQL(op ObjectpropertyT, creator string, db_type int) (esql stri
 		esql += base.SQLiteEscape(op.definition) + "," + base.SQLiteEscape(op.comment) + ",current_timestamp,current_timestamp)"
 	case base.MySQL:
 		esql += base.MySQLEscape(op.definition) + "," + base.MySQLEscape(op.comment) + ",now(),now())"
+	case PostgreSQL:
+		esql += pgEscape(op.definition) + "," + pgEscape(op.comment) + ",now(),now())"
 	}
 	return
 }
@@ -622,6 +656,9 @@ func UpdateEntitySQL(op ObjectpropertyT, db_type int, entity_id int64) (esql str
 	case base.MySQL:
 		esql += "definition=" + base.MySQLEscape(op.definition) + ","
 		esql += "description=" + base.MySQLEscape(op.comment) + ","
+	case PostgreSQL:
+		esql += "definition=" + pgEscape(op.definition) + ","
+		esql += "description=" + pgEscape(op.comment) + ","
 	}
 	esql += "time_updated=" + base.SQL_now()
 	esql += " where id=" + strconv.FormatInt(entity_id, 10)
@@ -651,7 +688,93 @@ func UpdateCodesetSQL(op ObjectpropertyT, db_type int, id int64) (csql string) {
 	return
 }
 
+// sqlArgsT collects the arguments of a parameterized statement, bind returns the placeholder of the dialect.
+type sqlArgsT struct {
+	db_type int
+	args    []any
+}
+
+func (sa *sqlArgsT) bind(v any) (placeholder string) {
+	sa.args = append(sa.args, v)
+	if sa.db_type == PostgreSQL {
+		placeholder = "$" + strconv.Itoa(len(sa.args))
+	} else {
+		placeholder = "?"
+	}
+	return
+}
+
+func sqlNow(db_type int) (now string) {
+	if db_type == base.SQLite {
+		now = "current_timestamp"
+	} else {
+		now = "now()"
+	}
+	return
+}
+
+// InsertEntityStmt is InsertEntitySQL as a query with database/sql arguments.
+func InsertEntityStmt(op ObjectpropertyT, creator string, db_type int) (query string, args []any) {
+	sa := sqlArgsT{db_type: db_type}
+	query = "insert into entity(code,creator,self_hierarchy,multiple_language,metatype_id,name,authority,thumbnail,definition,description,time_created,time_updated)"
+	query += " values(" + sa.bind(op.identifier) + "," + sa.bind(creator) + "," + sa.bind(base.Str2int(op.self_hierarchy)) + "," + sa.bind(base.Str2int(op.multi_language))
+	query += ",(select id from metatype where code=" + sa.bind(op.object_type) + ")," + sa.bind(op.caption) + ","
+	query += sa.bind(op.authority) + "," + sa.bind(op.logo) + "," + sa.bind(op.definition) + "," + sa.bind(op.comment) + ","
+	query += sqlNow(db_type) + "," + sqlNow(db_type) + ")"
+	args = sa.args
+	return
+}
+
+func UpdateEntityStmt(op ObjectpropertyT, db_type int, entity_id int64) (query string, args []any) {
+	sa := sqlArgsT{db_type: db_type}
+	query = "update entity set "
+	query += "self_hierarchy=" + sa.bind(base.Str2int(op.self_hierarchy)) + ","
+	query += "multiple_language=" + sa.bind(base.Str2int(op.multi_language)) + ","
+	query += "metatype_id=(select id from metatype where code=" + sa.bind(op.object_type) + "),"
+	query += "name=" + sa.bind(op.caption) + ","
+	query += "authority=" + sa.bind(op.authority) + ","
+	query += "thumbnail=" + sa.bind(op.logo) + ","
+	query += "definition=" + sa.bind(op.definition) + ","
+	query += "description=" + sa.bind(op.comment) + ","
+	query += "time_updated=" + sqlNow(db_type)
+	query += " where id=" + sa.bind(entity_id)
+	args = sa.args
+	return
+}
+
+func InsertCodesetStmt(op ObjectpropertyT, db_type int) (query string, args []any) {
+	if op.object_type == "codeset" && op.coding_type == "1" {
+		sa := sqlArgsT{db_type: db_type}
+		query = "insert into entity_codeset(id,coding_type,loading_mode,pad_format,code_structure,public_access,time_created,time_updated) values("
+		query += "(select id from entity where code=" + sa.bind(op.identifier) + ")," + sa.bind(base.Str2int(op.coding_type)) + "," + sa.bind(base.Str2int(op.loading_mode)) + ","
+		query += sa.bind(op.pad_format) + "," + sa.bind(op.code_structure) + "," + sa.bind(base.Str2int(op.public_access)) + "," + sqlNow(db_type) + "," + sqlNow(db_type) + ")"
+		args = sa.args
+	}
+	return
+}
+
+func UpdateCodesetStmt(op ObjectpropertyT, db_type int, id int64) (query string, args []any) {
+	if op.object_type == "codeset" && op.coding_type == "1" {
+		sa := sqlArgsT{db_type: db_type}
+		query = "update entity_codeset set "
+		query += "coding_type=" + sa.bind(base.Str2int(op.coding_type)) + ","
+		query += "loading_mode=" + sa.bind(base.Str2int(op.loading_mode)) + ","
+		query += "pad_format=" + sa.bind(op.pad_format) + ","
+		query += "code_structure=" + sa.bind(op.code_structure) + ","
+		query += "public_access=" + sa.bind(base.Str2int(op.public_access)) + ","
+		query += "time_updated=" + sqlNow(db_type)
+		query += " where id=" + sa.bind(id)
+		args = sa.args
+	}
+	return
+}
+
 func CreateIndexSQL(o gjson.Result, tablename string) (idxes, onfields []string, primary string) {
+	idxes, onfields, primary = createIndexSQL(o, tablename, base.DB_type)
+	return
+}
+
+func createIndexSQL(o gjson.Result, tablename string, db_type int) (idxes, onfields []string, primary string) {
 	o_indexes := o.Get("indexes")
 	if o_indexes.Exists() {
 		i_array := o_indexes.Array()
@@ -672,7 +795,7 @@ func CreateIndexSQL(o gjson.Result, tablename string) (idxes, onfields []string,
 					if strings.Contains(ss, " ") {
 						p_s := strings.Split(ss, " ")
 						if len(p_s) == 2 {
-							prop := "`" + p_s[0] + "`"
+							prop := sqlIdentifier(p_s[0], db_type)
 							props = append(props, p_s[0])
 							if index_type != "primary" {
 								sort := strings.ToUpper(p_s[1])
@@ -683,7 +806,7 @@ func CreateIndexSQL(o gjson.Result, tablename string) (idxes, onfields []string,
 							props_collation = append(props_collation, prop)
 						}
 					} else {
-						props_collation = append(props_collation, "`"+ss+"`")
+						props_collation = append(props_collation, sqlIdentifier(ss, db_type))
 						props = append(props, ss)
 					}
 				}
@@ -692,14 +815,19 @@ func CreateIndexSQL(o gjson.Result, tablename string) (idxes, onfields []string,
 					primary = index_properties
 				} else {
 					xx := "CREATE"
-					if index_type == "fulltext" {
+					if index_type == "fulltext" && db_type != PostgreSQL {
 						xx += " FULLTEXT"
 					}
-					inm := "`idx_" + tablename + "_" + index_name + "`"
-					if len(inm) > 64 {
-						inm = "I" + base.StrMD5(inm)
+					xx += " INDEX " + indexName(tablename, index_name, db_type) + " ON " + sqlIdentifier(tablename, db_type)
+					if index_type == "fulltext" && db_type == PostgreSQL { //tsvector over the concatenated columns
+						cc := []string{}
+						for _, prop := range props {
+							cc = append(cc, "coalesce("+sqlIdentifier(prop, db_type)+",'')")
+						}
+						xx += " USING GIN (to_tsvector('simple'," + strings.Join(cc, "||' '||") + "))"
+					} else {
+						xx += "(" + index_properties + ")"
 					}
-					xx += " INDEX " + inm + " ON `" + tablename + "`(" + index_properties + ")"
 					idxes = append(idxes, xx)
 					onfields = append(onfields, strings.Join(props, ","))
 				}
@@ -709,98 +837,57 @@ func CreateIndexSQL(o gjson.Result, tablename string) (idxes, onfields []string,
 	return
 }
 
-// normal: true - DEFAULT
-func property2SQL(objecttype string, v gjson.Result, db_type int, field_name, primary string, normal bool) (ff string) {
-	ff = "`" + field_name + "` "
-	field_default := v.Get("default").String()
+// indexName: the quoted index name, hashed when too long (PostgreSQL truncates a name over 63 bytes).
+func indexName(tablename, index_name string, db_type int) (inm string) {
+	name := "idx_" + tablename + "_" + index_name
+	inm = sqlIdentifier(name, db_type)
+	toolong := len(inm) > 64
+	if db_type == PostgreSQL {
+		toolong = len(name) > 63
+	}
+	if toolong {
+		inm = "I" + base.StrMD5(inm)
+	}
+	return
+}
+
+// columnType is the column data type of a property, without default, key or comment.
+func columnType(v gjson.Result, db_type int) (ct string) {
 	switch v.Get("type").String() {
 	case "time":
-		ff += "datetime"
-		if len(field_default) > 0 {
-			if field_default != "now" {
-				ff += " DEFAULT '" + field_default + "'"
-			} else {
-				if normal {
-					if db_type == base.SQLite {
-						ff += " DEFAULT current_timestamp"
-					}
-				}
-			}
+		if db_type == PostgreSQL {
+			ct = "timestamp"
 		} else {
-			ff += " DEFAULT '" + base.ZERO_TIME + "'"
+			ct = "datetime"
 		}
-	case "string", "password":
+	case "string", "password", "ipv4", "ipv6", "dotids":
 		size := base.DEFAULT_STRING_SIZE
-		o_size := v.Get("size")
-		if o_size.Exists() {
-			size = o_size.String()
-		}
-		ff += "varchar(" + size + ")"
-		ff += " DEFAULT '" + field_default + "'"
-	case "ipv4":
-		size := base.DEFAULT_IPV4_SIZE
-		o_size := v.Get("size")
-		if o_size.Exists() {
-			size = o_size.String()
-		}
-		ff += "varchar(" + size + ")"
-		ff += " DEFAULT '" + field_default + "'"
-	case "ipv6":
-		size := base.DEFAULT_IPV6_SIZE
-		o_size := v.Get("size")
-		if o_size.Exists() {
-			size = o_size.String()
+		switch v.Get("type").String() {
+		case "ipv4":
+			size = base.DEFAULT_IPV4_SIZE
+		case "ipv6":
+			size = base.DEFAULT_IPV6_SIZE
+		case "dotids":
+			size = base.DEFAULT_DOTIDS_SIZE
 		}
-		ff += "varchar(" + size + ")"
-		ff += " DEFAULT '" + field_default + "'"
-	case "dotids":
-		size := base.DEFAULT_DOTIDS_SIZE
 		o_size := v.Get("size")
 		if o_size.Exists() {
 			size = o_size.String()
 		}
-		ff += "varchar(" + size + ")"
-		ff += " DEFAULT '" + field_default + "'"
+		ct = "varchar(" + size + ")"
 	case "int":
 		switch db_type {
-		case base.SQLite:
-			ff += "INTEGER"
+		case base.SQLite, PostgreSQL:
+			ct = "INTEGER"
 		case base.MySQL:
-			ff += "int"
-		}
-		if "`"+field_name+"`" == primary {
-			ff += " PRIMARY KEY"
-			if objecttype == "object_extension" {
-				//id same as origin id,not autoincrement.
-			} else {
-				if normal {
-					switch db_type {
-					case base.SQLite:
-						ff += " AUTOINCREMENT"
-					case base.MySQL:
-						ff += " AUTO_INCREMENT"
-					}
-				}
-			}
-			ff += " NOT NULL"
-		} else {
-			if len(field_default) > 0 {
-				ff += " DEFAULT '" + field_default + "'"
-			} else {
-				ff += " DEFAULT '0'"
-			}
+			ct = "int"
 		}
 	case "float":
 		switch db_type {
-		case base.SQLite:
-			ff += "REAL"
+		case base.SQLite, PostgreSQL:
+			ct = "REAL"
 		case base.MySQL:
-			ff += "FLOAT"
-		}
-		if len(field_default) > 0 {
-			ff += " DEFAULT '" + field_default + "'"
-		} else {
-			ff += " DEFAULT '0'"
+			ct = "FLOAT"
 		}
 	case "decimal":
 		d := "2"
@@ -808,65 +895,128 @@ func property2SQL(objecttype string, v gjson.Result, db_type int, field_name, pr
 		if d_p.Exists() {
 			d = d_p.String()
 		}
-		ff += "decimal(20," + d + ")"
-		if len(field_default) > 0 {
-			ff += " DEFAULT '" + field_default + "'"
-		} else {
-			ff += " DEFAULT '0'"
-		}
+		ct = "decimal(20," + d + ")"
 	case "long":
-		ff += "bigint"
-		if len(field_default) > 0 {
-			ff += " DEFAULT '" + field_default + "'"
-		} else {
-			ff += " DEFAULT '0'"
-		}
+		ct = "bigint"
 	case "text":
 		capacity := v.Get("capacity").String()
-		switch capacity {
-		case "L", "long":
-			ff += "LONGTEXT"
-		case "M", "medium":
-			ff += "MEDIUMTEXT"
+		switch {
+		case db_type == PostgreSQL:
+			ct = "TEXT"
+		case capacity == "L" || capacity == "long":
+			ct = "LONGTEXT"
+		case capacity == "M" || capacity == "medium":
+			ct = "MEDIUMTEXT"
 		default:
-			ff += "TEXT"
+			ct = "TEXT"
 		}
-		/*if len(field_default) > 0 {//mysql can not set default value.
-			ff += " DEFAULT '" + field_default + "'"
-		} else {
-			ff += " DEFAULT ''"
-		}*/
 	case "blob":
 		capacity := v.Get("capacity").String()
-		switch capacity {
-		case "L", "long":
-			ff += "LONGBLOB"
-		case "M", "medium":
-			ff += "MEDIUMBLOB"
+		switch {
+		case db_type == PostgreSQL:
+			ct = "BYTEA"
+		case capacity == "L" || capacity == "long":
+			ct = "LONGBLOB"
+		case capacity == "M" || capacity == "medium":
+			ct = "MEDIUMBLOB"
 		default:
-			ff += "TEXT"
+			ct = "TEXT"
 		}
 	}
-	if normal {
-		o_comment := v.Get("comment").String()
-		o_pattern := v.Get("pattern").String()
-		if len(o_pattern) > 0 {
-			o_comment += " " + o_pattern
+	return
+}
+
+// normal: true - DEFAULT
+func property2SQL(objecttype string, v gjson.Result, db_type int, field_name, primary string, normal bool) (ff string) {
+	ff = sqlIdentifier(field_name, db_type) + " " + columnType(v, db_type)
+	if v.Get("type").String() == "int" && sqlIdentifier(field_name, db_type) == primary {
+		ff += " PRIMARY KEY"
+		if objecttype == "object_extension" {
+			//id same as origin id,not autoincrement.
+		} else {
+			if normal {
+				switch db_type {
+				case base.SQLite:
+					ff += " AUTOINCREMENT"
+				case base.MySQL:
+					ff += " AUTO_INCREMENT"
+				case PostgreSQL:
+					ff += " GENERATED BY DEFAULT AS IDENTITY"
+				}
+			}
 		}
+		ff += " NOT NULL"
+	} else if dflt := columnDefault(v, db_type, normal); len(dflt) > 0 {
+		ff += " DEFAULT " + dflt
+	}
+	if normal {
+		o_comment := propertyComment(v)
 		if len(o_comment) > 0 {
 			switch db_type {
 			case base.SQLite:
 				ff += " /*" + strings.Trim(base.SQLiteEscape(o_comment), "'") + "*/"
 			case base.MySQL:
 				ff += " COMMENT " + base.MySQLEscape(o_comment)
+			} //PostgreSQL: CreateCommentSQL
+		}
+	}
+	return
+}
+
+// columnDefault is the DEFAULT expression of a property that is not the primary key, empty for none.
+func columnDefault(v gjson.Result, db_type int, normal bool) (dflt string) {
+	field_default := v.Get("default").String()
+	switch v.Get("type").String() {
+	case "time":
+		if len(field_default) > 0 {
+			if field_default != "now" {
+				if db_type == PostgreSQL && field_default == base.ZERO_TIME {
+					field_default = "0001-01-01 00:00:00" //year 0000 is out of range
+				}
+				dflt = "'" + field_default + "'"
+			} else {
+				if normal {
+					if db_type == base.SQLite || db_type == PostgreSQL {
+						dflt = "current_timestamp"
+					}
+				}
+			}
+		} else {
+			if db_type == PostgreSQL {
+				dflt = "'0001-01-01 00:00:00'"
+			} else {
+				dflt = "'" + base.ZERO_TIME + "'"
 			}
 		}
+	case "string", "password", "ipv4", "ipv6", "dotids":
+		dflt = "'" + field_default + "'"
+	case "int", "float", "decimal", "long":
+		if len(field_default) > 0 {
+			dflt = "'" + field_default + "'"
+		} else {
+			dflt = "'0'"
+		}
+	case "text", "blob":
+		/*if len(field_default) > 0 {//mysql can not set default value.
+			ff += " DEFAULT '" + field_default + "'"
+		} else {
+			ff += " DEFAULT ''"
+		}*/
+	}
+	return
+}
+
+func propertyComment(v gjson.Result) (o_comment string) {
+	o_comment = v.Get("comment").String()
+	o_pattern := v.Get("pattern").String()
+	if len(o_pattern) > 0 {
+		o_comment += " " + o_pattern
 	}
 	return
 }
 
 func CreateTableSQL(o gjson.Result, roadmap []string, primary, creator string, db_type int, NEWLINE, TAB string) (asql string) {
-	asql = "CREATE TABLE `" + strings.Join(roadmap, "_") + "`"
+	asql = "CREATE TABLE " + sqlIdentifier(strings.Join(roadmap, "_"), db_type)
 	comment := o.Get("comment").String()
 	if len(comment) > 0 && db_type == base.SQLite {
 		asql += "/*" + strings.Trim(base.SQLiteEscape(comment), "'") + "*/"
@@ -903,6 +1053,30 @@ func CreateTableSQL(o gjson.Result, roadmap []string, primary, creator string, d
 	return
 }
 
+// CreateCommentSQL: PostgreSQL has no inline comment, table and column comments follow CREATE TABLE as COMMENT ON statements.
+func CreateCommentSQL(o gjson.Result, roadmap []string, db_type int) (cmts []string) {
+	if db_type == PostgreSQL {
+		tablename := sqlIdentifier(strings.Join(roadmap, "_"), db_type)
+		comment := o.Get("comment").String()
+		if len(comment) > 0 {
+			cmts = append(cmts, "COMMENT ON TABLE "+tablename+" IS "+pgEscape(comment))
+		}
+		o.ForEach(func(k, v gjson.Result) bool {
+			field_name := k.String()
+			if v.Type.String() == "JSON" && field_name != "indexes" {
+				if !strings.HasPrefix(v.Get("type").String(), "object") {
+					o_comment := propertyComment(v)
+					if len(o_comment) > 0 {
+						cmts = append(cmts, "COMMENT ON COLUMN "+tablename+"."+sqlIdentifier(field_name, db_type)+" IS "+pgEscape(o_comment))
+					}
+				}
+			}
+			return true
+		})
+	}
+	return
+}
+
 func UpdateTableSQL(o gjson.Result, roadmap []string, primary string, db_type int) (sqlsql []string) {
 	tablename := strings.Join(roadmap, "_")
 	//fmt.Println("UpdateTableSQL:", tablename)
@@ -918,22 +1092,18 @@ func UpdateTableSQL(o gjson.Result, roadmap []string, primary string, db_type in
 					if ti.FieldExists(field_name) {
 						short_propertySQL := property2SQL(objecttype, v, db_type, field_name, primary, false)
 						if !ti.SameProperty(field_name, short_propertySQL) {
-							switch db_type {
-							case base.SQLite: /*not support MODIFY COLUMN*/
-							case base.MySQL:
-								asql += " modify " + normal_propertySQL
-							}
+							asql += alterColumnSQL(v, db_type, field_name, primary, normal_propertySQL) /*SQLite not support MODIFY COLUMN*/
 						}
 					} else {
 						switch db_type {
-						case base.SQLite:
+						case base.SQLite, PostgreSQL:
 							asql += " ADD COLUMN " + normal_propertySQL
 						case base.MySQL:
 							asql += " add " + normal_propertySQL
 						}
 					}
 					if len(asql) > 0 {
-						sqlsql = append(sqlsql, "alter table `"+tablename+"`"+asql)
+						sqlsql = append(sqlsql, "alter table "+sqlIdentifier(tablename, db_type)+asql)
 					}
 				}
 			}
@@ -943,36 +1113,79 @@ func UpdateTableSQL(o gjson.Result, roadmap []string, primary string, db_type in
 	return
 }
 
+/*
+alterColumnSQL: the ALTER TABLE clause that changes an existing column, empty for SQLite.
+PostgreSQL alters the type, the default and the nullability apart, the primary key keeps its identity default.
+*/
+func alterColumnSQL(v gjson.Result, db_type int, field_name, primary, normal_propertySQL string) (asql string) {
+	switch db_type {
+	case base.MySQL:
+		asql = " modify " + normal_propertySQL
+	case PostgreSQL:
+		ct := columnType(v, db_type)
+		if len(ct) > 0 {
+			field := sqlIdentifier(field_name, db_type)
+			asql = " ALTER COLUMN " + field + " TYPE " + ct + " USING " + field + "::" + ct
+			if v.Get("type").String() == "int" && field == primary {
+				asql += ", ALTER COLUMN " + field + " SET NOT NULL"
+			} else {
+				if dflt := columnDefault(v, db_type, true); len(dflt) > 0 {
+					asql += ", ALTER COLUMN " + field + " SET DEFAULT " + dflt
+				} else {
+					asql += ", ALTER COLUMN " + field + " DROP DEFAULT"
+				}
+				asql += ", ALTER COLUMN " + field + " DROP NOT NULL"
+			}
+		}
+	}
+	return
+}
+
 func def2SQL(o gjson.Result, roadmap []string, creator string, db_type int, NEWLINE, TAB string) (ss, es []string) {
+	sss, ess := def2Statements(o, roadmap, creator, db_type, NEWLINE, TAB)
+	for _, st := range sss {
+		ss = append(ss, st.Up+";")
+	}
+	for _, st := range ess {
+		es = append(es, st.Up+";")
+	}
+	return
+}
+
+func def2Statements(o gjson.Result, roadmap []string, creator string, db_type int, NEWLINE, TAB string) (ss, es []StatementT) {
 	o_type := o.Get("type").String()
 	if strings.HasPrefix(o_type, "object") || o_type == "codeset" {
+		tablename := strings.Join(roadmap, "_")
 		op := GetObjectProperty(o, strings.Join(roadmap, "."))
 		asql := InsertEntitySQL(op, creator, db_type)
-		es = append(es, asql+";")
+		es = append(es, StatementT{"insert_entity", "entity", asql, "delete from entity where code=" + sqlString(op.identifier, db_type)})
 		asql = InsertCodesetSQL(op, db_type)
 		if len(asql) > 0 {
-			es = append(es, asql+";")
+			es = append(es, StatementT{"insert_codeset", "entity_codeset", asql, "delete from entity_codeset where id=(select id from entity where code=" + sqlString(op.identifier, db_type) + ")"})
 		}
 		o.ForEach(func(k, v gjson.Result) bool {
 			field_name := k.String()
 			if v.Type.String() == "JSON" && field_name != "indexes" {
 				o_type := v.Get("type").String()
 				if strings.HasPrefix(o_type, "o") { //codeset must not be in second level
-					vv, _ := def2SQL(v, append(roadmap, field_name), creator, db_type, NEWLINE, TAB) //sub-object recursive call
+					vv, _ := def2Statements(v, append(roadmap, field_name), creator, db_type, NEWLINE, TAB) //sub-object recursive call
 					ss = append(ss, vv...)
 				}
 			}
 			return true
 		})
-		idxes, _, primary := CreateIndexSQL(o, strings.Join(roadmap, "_"))
+		_, _, primary := createIndexSQL(o, tablename, db_type)
+		idxes := indexSQLs(o, tablename, db_type)
 		asql = CreateTableSQL(o, roadmap, primary, creator, db_type, NEWLINE, TAB)
 		if len(asql) > 0 {
-			if len(idxes) > 0 {
-				for _, x := range idxes {
-					ss = append(ss, x+";") //ss = append(ss, idxes...)
-				}
+			cmts := CreateCommentSQL(o, roadmap, db_type)
+			for i := len(cmts) - 1; i >= 0; i-- { //reversed below, keep declaration order
+				ss = append(ss, StatementT{"comment", tablename, cmts[i], ""}) //dropped with the table
+			}
+			for _, idx := range idxes {
+				ss = append(ss, StatementT{"create_index", tablename, idx.create, dropIndexSQL(tablename, idx.name, db_type)})
 			}
-			ss = append(ss, asql+";")
+			ss = append(ss, StatementT{"create_table", tablename, asql, "DROP TABLE " + sqlIdentifier(tablename, db_type)})
 		}
 	}
 	if len(roadmap) == 1 {
@@ -1006,6 +1219,9 @@ func DefinitionExtend(jsontxt []byte, identifier, NEWLINE, TAB, EMPHASIS string,
 		if m_l && result.Get("language").String() == "single" { //object close multiple language
 			m_l = false
 		}
+		if e = checkAccessLimits(result, []string{identifier}); e != nil {
+			return
+		}
 		definition, readability, e = extObject(result, []string{identifier}, []string{result.Get("type").String()}, m_l, NEWLINE, TAB, EMPHASIS)
 		if e == nil {
 			definition = "{" + quote(identifier) + ": " + definition + "}"
@@ -1072,12 +1288,6 @@ type shortcutT struct {
 	Action  string `json:"action"`
 	Caption string `json:"label"`
 }
-type hotkeyT struct {
-	Icon    string //"icon": "fa-clip-o",
-	Action  string //"action": "clip",
-	Caption string //"caption": "en:clip;zh:引用",
-	Style   string
-}
 type ColumnT struct {
 	Name       string
 	Property   string
@@ -1793,32 +2003,32 @@ func ParseComponentAction(component *gjson.Result, roadmaps string) (htmltxt, js
 				scene := a.Get("scene").String()
 				style := a.Get("style").String()
 				htmltxt += strings.Repeat("&nbsp;", 3)
-				htmltxt += `<span sub="{{sub}}" iid="{{iid}}" rmi="{{rmi}}" class="` + roadmaps + `_` + action + `">`
+				htmltxt += `<span sub="{{sub}}" iid="{{iid}}" rmi="{{rmi}}" class="` + escAttr(roadmaps+`_`+action) + `">`
 				htmltxt += `<i class="actionbtn `
 				if strings.HasPrefix(icon, "fa-") {
-					htmltxt += `fa ` + icon
+					htmltxt += `fa ` + escAttr(icon)
 				}
 				htmltxt += `"`
 				if len(style) > 0 {
-					htmltxt += ` style="` + style + `"`
+					htmltxt += ` style="` + escStyle(style) + `"`
 				}
 				htmltxt += `></i></span>`
 				switch action {
 				case "popform":
 					widgets = append(widgets, "popform")
-					jstxt += `$('.` + roadmaps + `_` + action + `').live('click',function(){`
+					jstxt += `$('.` + escJS(roadmaps+`_`+action) + `').live('click',function(){`
 					jstxt += `	var instance_id=$(this).attr('iid');`
 					jstxt += `	var rmi=$(this).attr('rmi');`
 					jstxt += `	$('body').Popform({i18n:page_i18n,`
 					jstxt += `		eid:{{.entity_id}},rmi:rmi,`
 					jstxt += `		instance_name: instance_name,`
-					jstxt += `		scene:'` + scene + `',`
+					jstxt += `		scene:'` + escJS(scene) + `',`
 					jstxt += `		afterSave:function(iid){cascadeRefresh(rmi,iid);}`
 					jstxt += `	}).setInstance(instance_id);`
 					jstxt += `});`
 				case "remove":
 					widgets = append(widgets, "yesno")
-					jstxt += `$('.` + roadmaps + `_` + action + `').live('click',function(){`
+					jstxt += `$('.` + escJS(roadmaps+`_`+action) + `').live('click',function(){`
 					jstxt += `	var instance_id=$(this).attr('iid');`
 					jstxt += `	var rmi=$(this).attr('rmi');`
 					jstxt += `	var subentity=$(this).attr('sub');`
@@ -1833,7 +2043,7 @@ func ParseComponentAction(component *gjson.Result, roadmaps string) (htmltxt, js
 					jstxt += `			});`
 					jstxt += `			$.ajaxSettings.async = true;`
 					jstxt += `		}`
-					jstxt += `	}).show_alertpane('','{{.txt_removeornot}}?','` + action + `');`
+					jstxt += `	}).show_alertpane('','{{.txt_removeornot}}?','` + escJS(action) + `');`
 					jstxt += `});`
 				}
 			}
@@ -1843,141 +2053,15 @@ func ParseComponentAction(component *gjson.Result, roadmaps string) (htmltxt, js
 }
 
 func ParseGrid(grid *gjson.Result, identifier, gridscene, clientlanguage_code string) (rows_per_page int, columns []ColumnT, shortcut_block, hot_block, shortcut_js, shortcut_case, action_js string, reference_properties, dependencies []string) {
-	hotkeys := []hotkeyT{}
-	shortcuts := []shortcutT{}
-	g_type := grid.Get("type").String()
-	if g_type == "grid" {
-		rows_per_page = int(grid.Get("rows_per_page").Int())
-		if rows_per_page == 0 {
-			rows_per_page = base.Str2int(base.GetConfigurationSimple("UI_ROWSPERPAGE"))
-		}
-		refers := []string{"id"}
-		grid.ForEach(func(k, v gjson.Result) bool {
-			name := k.String()
-			if name == "_shortcut_" {
-				if v.Type.String() == "JSON" {
-					if v.IsArray() {
-						a_a := v.Array()
-						for _, a := range a_a {
-							var s shortcutT
-							s.Icon = a.Get("icon").String()
-							s.Action = a.Get("action").String()
-							s.Caption = base.LanguageLabel(a.Get("caption").String(), clientlanguage_code)
-							bHotkey := a.Get("hotkey").Bool()
-							caption := s.Caption
-							if len(s.Icon) > 0 {
-								caption = base.Iconhtml(s.Icon) + caption
-							}
-							scene := a.Get("scene").String()
-							if s.Action == "popdoc" {
-								scene = strings.ReplaceAll(scene, "{{identifier}}", identifier)
-							}
-							js, dependency := shortcut_JS(bHotkey, gridscene, s.Action, scene, caption)
-							base.MergeDependency(&dependencies, dependency)
-							shortcut_js += js
-
-							shortcuts = append(shortcuts, s)
-							shortcut_case += `case '` + s.Action + `':` + s.Action + `();break;`
-							if bHotkey {
-								var hot hotkeyT
-								hot.Icon = a.Get("icon").String()
-								hot.Action = a.Get("action").String()
-								hot.Caption = base.LanguageLabel(a.Get("caption").String(), clientlanguage_code)
-								hot.Style = a.Get("style").String()
-								hotkeys = append(hotkeys, hot)
-							}
-						}
-					}
-				}
-			} else if !isReservedWord(name) {
-				if v.Type.String() == "JSON" {
-					if len(name) > 0 {
-						var col ColumnT
-						property := v.Get("property").String()
-						col.Name = name
-						col.Query = v.Get("query").String()
-						col.Transform = v.Get("transform").String()
-						col.Hyperlink = v.Get("hyperlink").String()
-						col.Mark = v.Get("mark").String()
-						col.Onclick = v.Get("onclick").String()
-						col.Align = v.Get("text-align").String()
-						col.Width = v.Get("width").String()
-						col.Order = v.Get("order").String()
-						col.Render = v.Get("render").String()
-						col.Comparison = v.Get("comparison").String()
-						col.Fixed = v.Get("fixed").String()
-						col.Property = property
-						/*if v.Get("all_language").String() == "true" { //use for GROUP_CONCAT order by o.id
-							if !strings.HasSuffix(property, "*") {
-								property += "*"
-							}
-						}*/
-						col.Visible = (v.Get("visible").String() != "false")
-						col.Caption = base.LanguageLabel(v.Get("caption").String(), clientlanguage_code)
-						actions := v.Get("actions")
-						if actions.Exists() {
-							if actions.IsArray() {
-								a_a := actions.Array()
-								for _, a := range a_a {
-									var action actionT
-									scene := a.Get("scene").String()
-									action.Icon = a.Get("icon").String()
-									action.Action = a.Get("action").String()
-									action.Scene = scene
-									action.Caption = base.LanguageLabel(a.Get("caption").String(), clientlanguage_code)
-									action.Hint = base.LanguageLabel(a.Get("hint").String(), clientlanguage_code)
-									condition := a.Get("condition").String()
-									properties := extractProperty(condition)
-									for _, v := range properties {
-										exists, _ := base.In_array(v, refers)
-										if !exists {
-											refers = append(refers, v)
-										}
-									}
-									action.Condition = condition
-									js, dependency := action_JS(scene, action.Action, scene, action.Hint)
-									action_js += js
-									base.MergeDependency(&dependencies, dependency)
-									col.Actions = append(col.Actions, action)
-								}
-							}
-						}
-						columns = append(columns, col)
-					}
-				}
-			}
-			return true // keep iterating
-		})
-		n := len(columns)
-		for _, v := range refers {
-			flag := true
-			for i := 0; i < n; i++ {
-				if v == columns[i].Property {
-					flag = false
-					break
-				}
-			}
-			if flag {
-				reference_properties = append(reference_properties, v)
-			}
-		}
-	}
-	n := len(hotkeys)
-	for i := 0; i < n; i++ {
-		hot := hotkeys[i]
-		hot_block += strings.Repeat("&nbsp;", 3)
-		hot_block += `<i id="` + gridscene + `_` + hot.Action + `_btn" class="toolbtn `
-		if strings.HasPrefix(hot.Icon, "fa-") {
-			hot_block += `fa ` + hot.Icon + ` fa-lg`
-		}
-		hot_block += `"`
-		if len(hot.Style) > 0 {
-			hot_block += ` style="` + hot.Style + `"`
-		}
-		hot_block += `></i>`
-	}
-	sb, _ := json.Marshal(shortcuts)
-	shortcut_block = string(sb)
+	return ParseGridPermitted(grid, identifier, gridscene, clientlanguage_code, nil)
+}
+
+// ParseGridPermitted is ParseGrid without the columns, actions and shortcuts perm does not allow, ParseGridSpec with its JS.
+func ParseGridPermitted(grid *gjson.Result, identifier, gridscene, clientlanguage_code string, perm *PermissionT) (rows_per_page int, columns []ColumnT, shortcut_block, hot_block, shortcut_js, shortcut_case, action_js string, reference_properties, dependencies []string) {
+	spec := ParseGridSpec(grid, identifier, gridscene, clientlanguage_code, perm)
+	rows_per_page, reference_properties, dependencies = spec.RowsPerPage, spec.References, spec.Dependencies
+	columns = spec.ColumnsT()
+	shortcut_block, hot_block, shortcut_js, shortcut_case, action_js = spec.JS(gridscene)
 	return
 }
 
@@ -1999,7 +2083,7 @@ func getStyle(component gjson.Result, properties string) (style []string) {
 		if len(name) > 0 {
 			value := component.Get(name).String()
 			if len(value) > 0 {
-				style = append(style, name+":"+value)
+				style = append(style, name+":"+escCSS(value))
 			}
 		}
 	}
@@ -2043,13 +2127,18 @@ type inputT struct {
 }
 
 func Filter2html(definition, object_definition gjson.Result, clientlanguage_code, sessionvalues string) (html string, properties []string, json_inputs string, input_types []string) {
+	return Filter2htmlPermitted(definition, object_definition, clientlanguage_code, sessionvalues, nil)
+}
+
+// Filter2htmlPermitted is Filter2html without the components of properties perm does not show.
+func Filter2htmlPermitted(definition, object_definition gjson.Result, clientlanguage_code, sessionvalues string, perm *PermissionT) (html string, properties []string, json_inputs string, input_types []string) {
 	if definition.Get("type").String() == "filter" {
 		subentity := definition.Get("subentity").String()
 		verticalalign := definition.Get("vertical-align").String()
 		html, json_inputs = "", ""
 		definition.ForEach(func(k, v gjson.Result) bool {
 			if v.Type.String() == "JSON" {
-				txt, propertyy, inputss := filtercomponent2html(k.String(), v, verticalalign, clientlanguage_code, sessionvalues)
+				txt, propertyy, inputss := filtercomponent2html(k.String(), v, verticalalign, clientlanguage_code, sessionvalues, perm)
 				html += txt
 				for _, property := range propertyy { //make unique
 					exists := false
@@ -2066,10 +2155,10 @@ func Filter2html(definition, object_definition gjson.Result, clientlanguage_code
 						if len(json_inputs) > 0 {
 							json_inputs += ","
 						}
-						json_inputs += `{"property":"` + inputss[i].Property + `",`
-						json_inputs += `"caption":"` + inputss[i].Caption + `",`
-						json_inputs += `"id":"` + inputss[i].FormID + `",`
-						json_inputs += `"type":"` + inputss[i].InputType + `",`
+						json_inputs += `{"property":"` + escJSON(inputss[i].Property) + `",`
+						json_inputs += `"caption":"` + escJSON(inputss[i].Caption) + `",`
+						json_inputs += `"id":"` + escJSON(inputss[i].FormID) + `",`
+						json_inputs += `"type":"` + escJSON(inputss[i].InputType) + `",`
 						exists, _ := base.In_array(inputss[i].InputType, input_types)
 						if !exists {
 							input_types = append(input_types, inputss[i].InputType)
@@ -2078,15 +2167,24 @@ func Filter2html(definition, object_definition gjson.Result, clientlanguage_code
 						switch inputss[i].InputType {
 						case "daterange":
 							//if len(inputss[i].Default) > 0 {
-							json_inputs += `"default":"` + inputss[i].Default + `",`
+							json_inputs += `"default":"` + escJSON(inputss[i].Default) + `",`
 							//}
+						case "numberrange":
+							json_inputs += `"default":"` + escJSON(inputss[i].Default) + `",`
+							t := object_definition.Get(inputss[i].Property + ".type").String()
+							params = append(params, `"number_type":"`+escJSON(t)+`"`)
+							if dp := object_definition.Get(inputss[i].Property + ".decimal_places"); dp.Exists() {
+								params = append(params, `"decimal_places":`+strconv.Itoa(int(dp.Int())))
+							}
+						case "toggle": //a ^[01]$ flag
+							json_inputs += `"default":"` + escJSON(inputss[i].Default) + `",`
 						case "chooser":
 							o := object_definition
 							r := o.Get(inputss[i].Property + ".options")
 							if r.Exists() {
-								params = append(params, `"entity":"`+r.String()+`"`)
+								params = append(params, `"entity":"`+escJSON(r.String())+`"`)
 							}
-						case "selector":
+						case "selector", "treeselector":
 							o := object_definition
 							if len(subentity) > 0 {
 								o = o.Get(subentity)
@@ -2094,7 +2192,7 @@ func Filter2html(definition, object_definition gjson.Result, clientlanguage_code
 							//fmt.Println("============", o.String())
 							r := o.Get(inputss[i].Property + ".options")
 							if r.Exists() {
-								params = append(params, `"codeset":"`+r.String()+`"`)
+								params = append(params, `"codeset":"`+escJSON(r.String())+`"`)
 							}
 							t := o.Get(inputss[i].Property + ".type")
 							if t.Exists() {
@@ -2113,6 +2211,9 @@ func Filter2html(definition, object_definition gjson.Result, clientlanguage_code
 							if exists {
 								params = append(params, `"multiple_choice":true`)
 							}
+							if leaf, _ := base.In_array("leaf", ss); leaf && inputss[i].InputType == "treeselector" { //treeselector:multiple|leaf
+								params = append(params, `"leaf_only":true`)
+							}
 						}
 						json_inputs += `"param":{` + strings.Join(params, ",") + `}}`
 					}
@@ -2125,9 +2226,12 @@ func Filter2html(definition, object_definition gjson.Result, clientlanguage_code
 	return
 }
 
-func filtercomponent2html(name string, component gjson.Result, verticalalign, clientlanguage_code, sessionvalues string) (html string, properties []string, inputs []inputT) {
+func filtercomponent2html(name string, component gjson.Result, verticalalign, clientlanguage_code, sessionvalues string, perm *PermissionT) (html string, properties []string, inputs []inputT) {
+	if !perm.CanSee(component.Get("property").String()) {
+		return
+	}
 	if name == "html" {
-		html = component.Get("text").String()
+		html = escText(TrustedHTML(component.Get("text").String())) //written as is
 		return
 	}
 	mapSession := base.String2MapOper(sessionvalues, "&", "=")
@@ -2138,7 +2242,7 @@ func filtercomponent2html(name string, component gjson.Result, verticalalign, cl
 
 	caption := base.LanguageLabel(component.Get("caption").String(), clientlanguage_code)
 	if len(caption) > 0 {
-		html += `<span class="caption" style="` + strings.Join(inline_style, ";") + `">` + caption + `:</span>`
+		html += `<span class="caption" style="` + strings.Join(inline_style, ";") + `">` + escText(caption) + `:</span>`
 	}
 	class := component.Get("class").String() //"class": "f-value"
 	property := component.Get("property").String()
@@ -2149,7 +2253,7 @@ func filtercomponent2html(name string, component gjson.Result, verticalalign, cl
 	if len(editor) > 0 {
 		sclass := ""
 		if len(class) > 0 {
-			sclass += ` class="` + class + `"`
+			sclass += ` class="` + escAttr(class) + `"`
 		}
 		defaultvalue := component.Get("default").String()
 		autofocus := component.Get("autofocus").String()
@@ -2161,7 +2265,7 @@ func filtercomponent2html(name string, component gjson.Result, verticalalign, cl
 		}
 		switch inputtype {
 		case "input":
-			html += `<input id="` + name + `"` + sclass
+			html += `<input id="` + escAttr(name) + `"` + sclass
 			if len(autofocus) > 0 {
 				html += ` autofocus="autofocus"`
 			}
@@ -2171,23 +2275,41 @@ func filtercomponent2html(name string, component gjson.Result, verticalalign, cl
 			html += ` style="` + strings.Join(is, ";") + `">`
 			//html += ` value="{{` + property + `}}">`
 		case "chooser":
-			html += `<div id="` + name + `" class="dd_chooser" tabindex="0"`
+			html += `<div id="` + escAttr(name) + `" class="dd_chooser" tabindex="0"`
 			is := getStyle(component, "width")
 			is = append(is, inline_style...)
 			is = append(is, "outline:none")
 			html += ` style="` + strings.Join(is, ";") + `"`
 			html += `></div>`
 		case "selector":
-			html += `<div id="` + name + `" class="h_selector" tabindex="0"`
+			html += `<div id="` + escAttr(name) + `" class="h_selector" tabindex="0"`
 			is := getStyle(component, "width")
 			is = append(is, inline_style...)
 			is = append(is, "outline:none")
 			html += ` style="` + strings.Join(is, ";") + `"`
 			html += `></div>`
+		case "treeselector":
+			html += `<div id="` + escAttr(name) + `" class="h_treeselector" tabindex="0"`
+			is := getStyle(component, "width")
+			is = append(is, inline_style...)
+			is = append(is, "outline:none")
+			html += ` style="` + strings.Join(is, ";") + `"`
+			html += `></div>`
+		case "numberrange":
+			html += `<span id="` + escAttr(name) + `" class="numberrange" tabindex="10"`
+			is := getStyle(component, "width")
+			is = append(is, inline_style...)
+			is = append(is, "outline:none")
+			html += ` style="` + strings.Join(is, ";") + `"`
+			html += `></span>`
+		case "toggle":
+			html += `<span id="` + escAttr(name) + `" class="toggle" tabindex="10"`
+			html += ` style="` + strings.Join(inline_style, ";") + `"`
+			html += `></span>`
 		case "wenhao":
-			html += `<span id="` + name + `" class="wenhao"></span>`
+			html += `<span id="` + escAttr(name) + `" class="wenhao"></span>`
 		case "daterange":
-			html += `<span id="` + name + `" class="daterange" tabindex="10"`
+			html += `<span id="` + escAttr(name) + `" class="daterange" tabindex="10"`
 			/*is := getStyle(component, "width")
 			is = append(is, "outline:none")
 			html += ` style="` + strings.Join(is, ";") + `"`*/
@@ -2214,7 +2336,7 @@ func filtercomponent2html(name string, component gjson.Result, verticalalign, cl
 	child_valign := component.Get("vertical-align").String()
 	component.ForEach(func(k, v gjson.Result) bool {
 		if v.Type.String() == "JSON" {
-			txt, propertyy, inputss := filtercomponent2html(k.String(), v, child_valign, clientlanguage_code, sessionvalues)
+			txt, propertyy, inputss := filtercomponent2html(k.String(), v, child_valign, clientlanguage_code, sessionvalues, perm)
 			html += txt
 			if len(propertyy) > 0 {
 				properties = append(properties, propertyy...)
@@ -2346,90 +2468,11 @@ func extractData(gr gjson.Result, path string) (data string) {
 	return
 }
 
+// TransformData is ApplyTransform with an empty result on error.
 func TransformData(property, data, transform, sessionvalues string) (dt string) {
-	if len(transform) > 0 {
-		mapSession := base.String2MapOper(sessionvalues, "&", "=")
-		tf := strings.SplitN(transform, "#", 2)
-		if len(tf) == 2 {
-			param := tf[1]
-			switch tf[0] {
-			case "replace": /* src/des;src1/des1;src2/des2 */
-				dt = data
-				sdsd := strings.Split(param, ";")
-				for i := 0; i < len(sdsd); i++ {
-					s_d := strings.Split(sdsd[i], "/")
-					if len(s_d) == 2 {
-						dt = strings.ReplaceAll(dt, s_d[0], s_d[1])
-					}
-				}
-			case "equalto":
-				dt = "false"
-				if strings.Compare(data, param) == 0 {
-					dt = "true"
-				}
-			case "fill_templet": // /view?idf=article&iid=[id]
-				dt = strings.Replace(param, "["+property+"]", data, -1)
-			case "cut_left":
-				maxlen := base.Str2int(param)
-				if maxlen > 0 {
-					dt = base.FirstWords(data, maxlen)
-				}
-			case "data_extract":
-				if len(data) > 0 && len(param) > 0 { //images.src
-					if gjson.Valid(data) {
-						dt = extractData(gjson.Parse(data), param)
-					}
-				}
-			case "date_format":
-				if v, ok := mapSession["dateformat_preferred"]; ok {
-					param = v
-				}
-				tm, e := base.Str20time(data)
-				if e == nil {
-					if !tm.IsZero() && !base.IsZero(tm) {
-						dt = tm.Format(base.DateTimeLayout(param))
-					}
-				}
-			case "datetime_format":
-				dateformat, timeformat := "2006-01-02", "15:04:05"
-				if v, ok := mapSession["dateformat_preferred"]; ok {
-					dateformat = v
-				}
-				if v, ok := mapSession["timeformat_preferred"]; ok {
-					timeformat = v
-				}
-				tm, e := base.Str20time(data)
-				if e == nil {
-					if param == "humanized" {
-						clientlanguage_id := base.BaseLanguage_id()
-						if v, ok := mapSession["clientlanguage_id"]; ok {
-							clientlanguage_id = v
-						}
-						dt = base.HumanizedTime(tm, dateformat, timeformat, clientlanguage_id)
-					} else {
-						if len(param) == 0 {
-							param = dateformat + " " + timeformat
-						}
-						if !tm.IsZero() && !base.IsZero(tm) {
-							dt = tm.Format(base.DateTimeLayout(param))
-						}
-					}
-				}
-			case "poplink":
-				dt = `<a href="/poplink?z=` + base.EncodeParam(data) + `" target="_blank">` + param + `</a>`
-			case "hyperlink":
-				dt = `<a href="` + data + `">` + param + `</a>`
-			}
-		} else {
-			switch transform {
-			case "LF2BR":
-				dt = strings.ReplaceAll(data, "\n", "<br>")
-			case "base64":
-				dt = "bs64:" + base64.StdEncoding.EncodeToString([]byte(data))
-			}
-		}
-	} else {
-		dt = data
+	dt, e := ApplyTransform(property, data, transform, sessionvalues)
+	if e != nil {
+		dt = ""
 	}
 	return
 }
@@ -2471,12 +2514,12 @@ func Appendix2html(b []byte) (html string, e error) {
 						if len(align) == 0 {
 							align = "left"
 						}
-						style = append(style, "text-align:"+align)
+						style = append(style, "text-align:"+escCSS(align))
 						valign := apxes.Valign
 						if len(valign) == 0 {
 							valign = "middle"
 						}
-						style = append(style, "vertical-align:"+valign)
+						style = append(style, "vertical-align:"+escCSS(valign))
 					} else {
 						class = "blank"
 					}
@@ -2484,7 +2527,7 @@ func Appendix2html(b []byte) (html string, e error) {
 					sclass := ` class="` + class + `"`
 					html += "<td" + sclass + sstyle + ">"
 					if k < n {
-						html += `<a id="` + base.StrMD5(apx.Src) + `" href="` + apx.Src + `">` + apx.Tag + `</a>`
+						html += `<a id="` + base.StrMD5(apx.Src) + `" href="` + escURL(apx.Src) + `">` + escText(apx.Tag) + `</a>`
 					}
 					html += "</td>"
 				}
@@ -2535,12 +2578,12 @@ func Illustration2html(b []byte) (html string, e error) {
 						if len(align) == 0 {
 							align = illustration.Align
 						}
-						style = append(style, "text-align:"+align)
+						style = append(style, "text-align:"+escCSS(align))
 						valign := image.Valign
 						if len(valign) == 0 {
 							valign = illustration.Valign
 						}
-						style = append(style, "vertical-align:"+valign)
+						style = append(style, "vertical-align:"+escCSS(valign))
 					} else {
 						class = "blank"
 					}
@@ -2548,7 +2591,7 @@ func Illustration2html(b []byte) (html string, e error) {
 					sclass := ` class="` + class + `"`
 					html += "<td" + sclass + sstyle + ">"
 					if k < n {
-						html += `<img style="max-width:100%" id="` + base.StrMD5(image.Src) + `" src="` + image.Src + `" alt="` + image.Tag + `">`
+						html += `<img style="max-width:100%" id="` + base.StrMD5(image.Src) + `" src="` + escURL(image.Src) + `" alt="` + escAttr(image.Tag) + `">`
 					}
 					html += "</td>"
 				}
@@ -2565,6 +2608,10 @@ type imageRT struct {
 	Tag string `json:"tag"`
 }
 
+/*
+AssembleRender renders val by render: "name:param" of a registered Renderer or the ternary "value?yes:no".
+An empty render gives val escaped, an unknown name gives val escaped and an error wrapping ErrUnknownRenderer.
+*/
 func AssembleRender(render, val string) (txt string, e error) {
 	flag := false
 	if strings.Contains(render, "?") && strings.Contains(render, ":") {
@@ -2591,140 +2638,27 @@ func AssembleRender(render, val string) (txt string, e error) {
 	}
 	if !flag {
 		k, v := base.SplitK_V(render, ":")
-		switch k {
-		case "decoder":
-			txt = `<div class="decoder" dt="` + val + `"></div>`
-		case "qrcode":
-			txt = `<img src="/qrcode?`
-			el := base.Str2int(v)
-			if el > 0 { //edgelength
-				txt += "el=" + strconv.Itoa(el) + "&"
-			}
-			txt += `dt=` + val + `">`
-		case "codestructurediagram":
-			if strings.Contains(val, "|") {
-				txt = `<img src="/csd?dt=` + base.EncodeParam(val) + `">`
-			}
-		case "pictable":
-			txt = `<table class="pictable" bs64dt="` + base.Encode("base64", val) + `" param="` + v + `" style="margin:0 auto;"></table>`
-		case "mdtable":
-			txt = `<div class="mdtable" dt="` + val + `" param="` + v + `" style="margin:0 auto;"></div>`
-		case "fdtable":
-			txt = `<div class="fdtable" dt="` + val + `" param="` + v + `" style="margin:0 auto;"></div>`
-		case "embeddedview":
-			txt = `<div class="embeddedview" dt="` + val + `" param="` + v + `" style="margin:0 auto;"></div>`
-		case "tianditu":
-			width, height := base.SplitK_V(v, "*")
-			wh := "width: "
-			if len(width) > 0 {
-				wh += width
-			} else {
-				wh += "640"
-			}
-			wh += "px; height: "
-			if len(height) > 0 {
-				wh += height
-			} else {
-				wh += "480"
-			}
-			wh += "px;"
-			txt = `<div id="tianditu" class="tianditu" address="` + val + `" style="` + wh + ` margin:0 auto; border: 1px solid gray;"></div>`
-		case "appendixes":
-			if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
-				txt, e = Appendix2html([]byte(val))
-			}
-		case "image":
-			width, height := base.SplitK_V(v, "*")
-			wh := ""
-			if len(width) > 0 {
-				wh += ` width="` + width + `"`
-			}
-			if len(height) > 0 {
-				wh += ` height="` + height + `"`
-			}
-
-			//{"columns":1,"images":[{"tag":"bigdata.gif","src":"/u?n=illu_d6b34d4d6feedc93f6c614030d18d28f-d0f49d88c867aef9f907e745ba83449b.gif","extension":".gif"}]}
-			if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
-				txt, e = Illustration2html([]byte(val))
-			} else {
-				ss := strings.Split(val, ",")
-				m := len(ss)
-				for j := 0; j < m; j++ {
-					data := ss[j]
-					if len(data) > 0 {
-						if strings.Contains(data, ".") {
-							txt += `<img src="` + data + `"` + wh + `>`
-						} else {
-							b, e := base64.StdEncoding.DecodeString(data)
-							if e == nil {
-								var img imageRT
-								e = json.Unmarshal(b, &img)
-								if e == nil {
-									txt += `<img src="` + img.Url + `" alt="` + img.Tag + `"` + wh + `>`
-								}
-							}
-						}
-					}
-				}
-			}
-		default:
-			txt = val
+		if len(k) == 0 {
+			txt = escText(val)
+		} else {
+			txt, e = renderBy(renderers, k, v, val)
 		}
 	}
 	return
 }
-func AssembleLeverTxt(lever, param string) (txt string) {
-	switch lever {
-	case "spinner":
-		txt = `<div class="` + lever + `" param="` + param + `"></div>`
-	case "gallery":
-		txt = `<div class="` + lever + `" param="` + param + `"></div>`
-	}
+
+// AssembleLeverTxt renders a lever without a value (spinner, gallery), an unknown one is an error.
+func AssembleLeverTxt(lever, param string) (txt string, e error) {
+	txt, e = renderBy(levers, lever, param, "")
 	return
 }
+
+// AssembleLever renders val by a registered lever, an empty lever gives val escaped, an unknown one val escaped and an error.
 func AssembleLever(lever, param, val string) (txt string, e error) {
-	flag := false
-	/*if strings.Contains(render, "?") && strings.Contains(render, ":") {
-		r, err := regexp.Compile("^[a-z0-9]+\\?[a-z]+:[a-z]+$")
-		if err == nil {
-			if r.MatchString(render) {
-				ss := strings.Split(render, "?")
-				if len(ss) == 2 {
-					vv := strings.Split(ss[1], ":")
-					if len(vv) == 2 {
-						if ss[0] == val {
-							txt = vv[0]
-						} else {
-							txt = vv[1]
-						}
-						txt = "{{.t_" + txt + "}}"
-					}
-					flag = true
-				}
-			}
-		} else {
-			e = errors.New(render + ":" + err.Error())
-		}
-	}*/
-	if !flag {
-		switch lever {
-		case "embeddedview":
-			bs64 := base64.StdEncoding.EncodeToString([]byte(val))
-			txt = `<div class="` + lever + `" dt="` + bs64 + `" param="` + param + `"></div>`
-		case "dimensionradio":
-			txt = `<div class="` + lever + `" dt="` + val + `" param="` + param + `"></div>`
-
-		/*
-			case "pictable":
-				txt = `<table class="pictable" bs64dt="` + base.Encode("base64", val) + `" param="` + v + `" style="margin:0 auto;"></table>`
-			case "mdtable":
-				txt = `<div class="mdtable" dt="` + val + `" param="` + v + `" style="margin:0 auto;"></div>`
-			case "fdtable":
-				txt = `<div class="fdtable" dt="` + val + `" param="` + v + `" style="margin:0 auto;"></div>`
-		*/
-		default:
-			txt = val
-		}
+	if len(lever) == 0 {
+		txt = escText(val)
+	} else {
+		txt, e = renderBy(levers, lever, param, val)
 	}
 	return
 }
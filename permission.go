@@ -0,0 +1,99 @@
+package object
+
+import (
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+/*
+PermissionT is what a usertype may do with an object, from the "permissions" array of its definition:
+
+	"permissions": [
+		{"usertype": "admin", "operations": "*", "visible": "*", "editable": "*"},
+		{"usertype": "editor,author", "operations": "read,create,update,release,revoke", "visible": "*", "editable": "title,summary"},
+		{"usertype": "all", "operations": "read", "visible": "title,summary"}
+	]
+
+Every entry of the usertype (or of "all") adds to the permission. Operations are read, create, update, remove
+and the names of grid actions, an action is also allowed by the operation it implies (popform: update).
+An editable property is visible. A nil *PermissionT allows everything.
+*/
+type PermissionT struct {
+	Operations []string
+	Visible    []string
+	Editable   []string
+}
+
+// actionOperations: the operation implied by a grid action or shortcut.
+var actionOperations = map[string]string{
+	"addnew": "create", "popnew": "create", "paste": "create", "reply": "create",
+	"form": "update", "popform": "update", "popitem": "update", "labeling": "update", "recommendorder": "update",
+	"remove": "remove", "empty": "remove", "emptyentity": "remove", "recommendremove": "remove",
+	"view": "read", "popview": "read", "popgrid": "read", "popchart": "read", "popdoc": "read", "table2csv": "read", "export": "read",
+	"select": "read", "pick": "read", "choose": "read", "optiongrid": "read", "intersect": "read", "occurrence": "read",
+}
+
+func splitList(txt string) (ss []string) {
+	for _, s := range strings.Split(txt, ",") {
+		if s = base.TrimBLANK(s); len(s) > 0 {
+			ss = append(ss, s)
+		}
+	}
+	return
+}
+
+func addUnique(ss *[]string, vv []string) {
+	for _, v := range vv {
+		if exists, _ := base.In_array(v, *ss); !exists {
+			*ss = append(*ss, v)
+		}
+	}
+}
+
+// UserPermission merges the "permissions" entries of o for user_type, nil without "permissions".
+func UserPermission(o gjson.Result, user_type int) (perm *PermissionT) {
+	permissions := o.Get("permissions")
+	if permissions.IsArray() {
+		perm = &PermissionT{}
+		usertype := base.GetUsertype(user_type)
+		for _, row := range permissions.Array() {
+			utut := splitList(row.Get("usertype").String())
+			all, _ := base.In_array("all", utut)
+			mine, _ := base.In_array(usertype, utut)
+			if all || mine {
+				addUnique(&perm.Operations, splitList(row.Get("operations").String()))
+				addUnique(&perm.Visible, splitList(row.Get("visible").String()))
+				addUnique(&perm.Editable, splitList(row.Get("editable").String()))
+			}
+		}
+	}
+	return
+}
+
+func permitted(list []string, name string) bool {
+	all, _ := base.In_array("*", list)
+	exists, _ := base.In_array(name, list)
+	return all || exists
+}
+
+// Allow: operation or grid action allowed.
+func (perm *PermissionT) Allow(operation string) bool {
+	if perm == nil || permitted(perm.Operations, operation) {
+		return true
+	}
+	implied, ok := actionOperations[operation]
+	return ok && permitted(perm.Operations, implied)
+}
+
+// CanSee: property visible, a lookup like "user_id^user.name" by its own property.
+func (perm *PermissionT) CanSee(property string) bool {
+	property = exactProperty(property)
+	return perm == nil || len(property) == 0 || permitted(perm.Visible, property) || permitted(perm.Editable, property)
+}
+
+// CanEdit: property editable.
+func (perm *PermissionT) CanEdit(property string) bool {
+	return perm == nil || permitted(perm.Editable, exactProperty(property))
+}
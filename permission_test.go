@@ -0,0 +1,50 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestPermission(t *testing.T) {
+	o := gjson.Parse(`{"permissions":[
+		{"usertype":"nosuchtype","operations":"*","visible":"*","editable":"*"},
+		{"usertype":"all","operations":"read,update","visible":"title","editable":"summary"},
+		{"usertype":"all","operations":"popview","visible":"summary"}]}`)
+	perm := UserPermission(o, -1)
+	if perm == nil {
+		t.Fatal("UserPermission: nil")
+	}
+	if want := []string{"read", "update", "popview"}; !reflect.DeepEqual(perm.Operations, want) {
+		t.Errorf("Operations = %q, want %q", perm.Operations, want)
+	}
+	for operation, ok := range map[string]bool{"read": true, "update": true, "popform": true, "view": true, "popview": true,
+		"create": false, "addnew": false, "remove": false, "release": false} {
+		if perm.Allow(operation) != ok {
+			t.Errorf("Allow(%q) = %v", operation, !ok)
+		}
+	}
+	for property, ok := range map[string]bool{"title": true, "summary": true, "user_id^user.name": false, "body": false, "": true} {
+		if perm.CanSee(property) != ok {
+			t.Errorf("CanSee(%q) = %v", property, !ok)
+		}
+	}
+	for property, ok := range map[string]bool{"summary": true, "title": false, "body": false} {
+		if perm.CanEdit(property) != ok {
+			t.Errorf("CanEdit(%q) = %v", property, !ok)
+		}
+	}
+
+	if UserPermission(gjson.Parse(`{}`), -1) != nil {
+		t.Error("UserPermission without permissions: not nil")
+	}
+	var none *PermissionT
+	if !none.Allow("remove") || !none.CanSee("body") || !none.CanEdit("body") {
+		t.Error("nil permission does not allow everything")
+	}
+	all := &PermissionT{Operations: []string{"*"}, Visible: []string{"*"}, Editable: []string{"*"}}
+	if !all.Allow("anything") || !all.CanSee("user_id^user.name") || !all.CanEdit("body") {
+		t.Error("* does not allow everything")
+	}
+}
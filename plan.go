@@ -0,0 +1,60 @@
+package object
+
+import (
+	"errors"
+
+	"github.com/tidwall/gjson"
+)
+
+/*
+StatementT is one statement of a definition deployment.
+Kind: create_table, create_index, comment, insert_entity, insert_codeset.
+Down undoes Up, empty when nothing is left to undo (comments go with their table).
+*/
+type StatementT struct {
+	Kind  string
+	Table string
+	Up    string
+	Down  string
+}
+
+// SQLPlan: tables and indexes first, entity and entity_codeset rows after, in execution order.
+type SQLPlan []StatementT
+
+func (plan SQLPlan) Up() (sqlsql []string) {
+	for _, st := range plan {
+		sqlsql = append(sqlsql, st.Up+";")
+	}
+	return
+}
+
+// Down rolls the plan back in reverse order.
+func (plan SQLPlan) Down() (sqlsql []string) {
+	for i := len(plan) - 1; i >= 0; i-- {
+		if len(plan[i].Down) > 0 {
+			sqlsql = append(sqlsql, plan[i].Down+";")
+		}
+	}
+	return
+}
+
+// String is the dry-run output, each statement headed by its kind and table.
+func (plan SQLPlan) String() (txt string) {
+	for _, st := range plan {
+		txt += "-- " + st.Kind + " " + st.Table + CRLF + st.Up + ";" + CRLF
+	}
+	return
+}
+
+// Definition2Plan is Definition2SQL with tagged, reversible statements.
+func Definition2Plan(definition, identifier, creator string, db_type int, NEWLINE, TAB string) (plan SQLPlan, e error) {
+	result := gjson.Get(definition, identifier)
+	if result.Exists() {
+		ss, es := def2Statements(result, []string{identifier}, creator, db_type, NEWLINE, TAB)
+		plan = append(plan, ss...)
+		plan = append(plan, es...)
+	} else {
+		e = errors.New(identifier + " syntax error!")
+	}
+	return
+}
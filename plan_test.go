@@ -0,0 +1,157 @@
+package object
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/svcbase/base"
+	_ "modernc.org/sqlite"
+)
+
+const planDefinition = `{"book":{"type":"object","id":{"type":"int"},"title":{"type":"string","size":50},"isbn":{"type":"string","size":20},
+	"chapter":{"type":"object","id":{"type":"int"},"title":{"type":"string","size":50},
+		"indexes":[{"name":"pk","type":"primary","properties":"id"},{"properties":"title"}]},
+	"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"a","properties":"title"},{"properties":"isbn"},{"name":"a","properties":"isbn,title"}]}}`
+
+func TestDefinition2Plan(t *testing.T) {
+	plan, e := Definition2Plan(planDefinition, "book", "tester", base.SQLite, "", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	got := []string{}
+	for _, st := range plan {
+		got = append(got, st.Kind+" "+st.Table)
+	}
+	want := []string{"create_table book", "create_index book", "create_index book", "create_index book",
+		"create_table book_chapter", "create_index book_chapter", "insert_entity entity"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("plan = %q, want %q", got, want)
+	}
+	sqlsql, entitysql, e := Definition2SQL(planDefinition, "book", "tester", base.SQLite, "", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if up := plan.Up(); !reflect.DeepEqual(up, append(sqlsql, entitysql...)) {
+		t.Errorf("Up =\n%s\nDefinition2SQL =\n%s", strings.Join(up, "\n"), strings.Join(append(sqlsql, entitysql...), "\n"))
+	}
+	wantDown := []string{
+		"delete from entity where code='book';",
+		"DROP INDEX `idx_book_chapter_`;",
+		"DROP TABLE `book_chapter`;",
+		"DROP INDEX `idx_book_a`;",
+		"DROP INDEX `idx_book_`;",
+		"DROP INDEX `idx_book_a`;",
+		"DROP TABLE `book`;",
+	}
+	if down := plan.Down(); !reflect.DeepEqual(down, wantDown) {
+		t.Errorf("Down =\n%s\nwant\n%s", strings.Join(down, "\n"), strings.Join(wantDown, "\n"))
+	}
+}
+
+// openTestDB: a SQLite database in a temporary file, with the metatype, entity and entity_codeset tables.
+func openTestDB(t *testing.T) (db *sql.DB) {
+	t.Helper()
+	db, e := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	for _, query := range []string{
+		"CREATE TABLE metatype(id INTEGER PRIMARY KEY AUTOINCREMENT,code varchar(32))",
+		"INSERT INTO metatype(code) VALUES('object'),('codeset')",
+		"CREATE TABLE entity(id INTEGER PRIMARY KEY AUTOINCREMENT,code varchar(64),creator varchar(64),self_hierarchy INTEGER,multiple_language INTEGER," +
+			"metatype_id INTEGER,name varchar(128),authority varchar(16),thumbnail varchar(255),definition TEXT,description TEXT,time_created datetime,time_updated datetime)",
+		"CREATE TABLE entity_codeset(id INTEGER PRIMARY KEY,coding_type INTEGER,loading_mode INTEGER,pad_format varchar(32),code_structure varchar(64)," +
+			"public_access INTEGER,time_created datetime,time_updated datetime)",
+	} {
+		if _, e = db.Exec(query); e != nil {
+			t.Fatal(e)
+		}
+	}
+	return
+}
+
+// sqliteObjects: the names of the tables or indexes (kind) of the database.
+func sqliteObjects(t *testing.T, db *sql.DB, kind string) (names map[string]bool) {
+	t.Helper()
+	names = make(map[string]bool)
+	rows, e := db.Query("SELECT name FROM sqlite_master WHERE type=? AND name NOT LIKE 'sqlite!_%' ESCAPE '!'", kind)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if e = rows.Scan(&name); e != nil {
+			t.Fatal(e)
+		}
+		names[name] = true
+	}
+	return
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...any) (n int) {
+	t.Helper()
+	if e := db.QueryRow(query, args...).Scan(&n); e != nil {
+		t.Fatal(e)
+	}
+	return
+}
+
+func TestDefinition2PlanUpDown(t *testing.T) {
+	definition := strings.Replace(planDefinition, `{"name":"a","properties":"isbn,title"}`, `{"name":"b","properties":"isbn,title"}`, 1)
+	plan, e := Definition2Plan(definition, "book", "tester", base.SQLite, "", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	db := openTestDB(t)
+	for _, query := range plan.Up() {
+		if _, e = db.Exec(query); e != nil {
+			t.Fatalf("%s: %v", query, e)
+		}
+	}
+	if indexes := sqliteObjects(t, db, "index"); len(indexes) != 4 {
+		t.Errorf("indexes after Up: %v", indexes)
+	}
+	for _, query := range plan.Down() {
+		if _, e = db.Exec(query); e != nil {
+			t.Fatalf("%s: %v", query, e)
+		}
+	}
+	tables := sqliteObjects(t, db, "table")
+	if tables["book"] || tables["book_chapter"] || len(sqliteObjects(t, db, "index")) > 0 {
+		t.Errorf("left after Down: %v", tables)
+	}
+	if n := countRows(t, db, "SELECT COUNT(*) FROM entity"); n != 0 {
+		t.Errorf("entity: %d rows left after Down", n)
+	}
+}
+
+func TestDefinition2PlanDownEscape(t *testing.T) {
+	definition := `{"o'b":{"type":"codeset","coding_type":"hierarchical","id":{"type":"int"},"code":{"type":"string"}}}`
+	for _, tt := range []struct {
+		db_type int
+		literal string
+	}{
+		{base.SQLite, `'o''b'`},
+		{base.MySQL, `'o\'b'`},
+		{PostgreSQL, `'o''b'`},
+	} {
+		plan, e := Definition2Plan(definition, "o'b", "tester", tt.db_type, "", "")
+		if e != nil {
+			t.Fatal(e)
+		}
+		down := plan.Down()
+		want := []string{
+			"delete from entity_codeset where id=(select id from entity where code=" + tt.literal + ");",
+			"delete from entity where code=" + tt.literal + ";",
+		}
+		if len(down) < 2 || !reflect.DeepEqual(down[:2], want) {
+			t.Errorf("%d: Down = %q, want %q first", tt.db_type, down, want)
+		}
+	}
+}
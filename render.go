@@ -0,0 +1,200 @@
+package object
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/svcbase/base"
+)
+
+// Renderer turns a property value into html, param is the text after ":" of the render ("qrcode:200" gives "200").
+type Renderer interface {
+	Render(param, val string) (string, error)
+}
+
+// RendererFunc adapts a function to Renderer.
+type RendererFunc func(param, val string) (string, error)
+
+func (f RendererFunc) Render(param, val string) (string, error) {
+	return f(param, val)
+}
+
+// ErrUnknownRenderer is returned (wrapped with the name) by AssembleRender and AssembleLever for a name not registered.
+var ErrUnknownRenderer = errors.New("unknown renderer")
+
+var (
+	renderMutex sync.RWMutex
+	renderers   = make(map[string]Renderer)
+	levers      = make(map[string]Renderer)
+)
+
+// RegisterRenderer adds (or replaces) a render of AssembleRender.
+func RegisterRenderer(name string, r Renderer) {
+	renderMutex.Lock()
+	renderers[name] = r
+	renderMutex.Unlock()
+}
+
+// RegisterLever adds (or replaces) a lever of AssembleLever.
+func RegisterLever(name string, r Renderer) {
+	renderMutex.Lock()
+	levers[name] = r
+	renderMutex.Unlock()
+}
+
+// Renderers: the registered renderer names.
+func Renderers() (names []string) {
+	renderMutex.RLock()
+	names = sortedKeys(renderers)
+	renderMutex.RUnlock()
+	return
+}
+
+// Levers: the registered lever names.
+func Levers() (names []string) {
+	renderMutex.RLock()
+	names = sortedKeys(levers)
+	renderMutex.RUnlock()
+	return
+}
+
+// renderBy: the registered renderer of name, an unknown one echoes val escaped with ErrUnknownRenderer.
+func renderBy(registry map[string]Renderer, name, param, val string) (txt string, e error) {
+	renderMutex.RLock()
+	r, ok := registry[name]
+	renderMutex.RUnlock()
+	if ok {
+		txt, e = r.Render(param, val)
+	} else {
+		txt = escText(val)
+		e = &unknownRendererError{name}
+	}
+	return
+}
+
+type unknownRendererError struct {
+	name string
+}
+
+func (ure *unknownRendererError) Error() string {
+	return ErrUnknownRenderer.Error() + ": " + ure.name
+}
+
+func (ure *unknownRendererError) Unwrap() error {
+	return ErrUnknownRenderer
+}
+
+func init() {
+	RegisterRenderer("decoder", RendererFunc(func(v, val string) (txt string, e error) {
+		txt = `<div class="decoder" dt="` + escAttr(val) + `"></div>`
+		return
+	}))
+	RegisterRenderer("qrcode", RendererFunc(func(v, val string) (txt string, e error) {
+		txt = `<img src="/qrcode?`
+		el := base.Str2int(v)
+		if el > 0 { //edgelength
+			txt += "el=" + strconv.Itoa(el) + "&"
+		}
+		txt += `dt=` + escAttr(url.QueryEscape(val)) + `">`
+		return
+	}))
+	RegisterRenderer("codestructurediagram", RendererFunc(func(v, val string) (txt string, e error) {
+		if strings.Contains(val, "|") {
+			txt = `<img src="/csd?dt=` + escAttr(base.EncodeParam(val)) + `">`
+		}
+		return
+	}))
+	RegisterRenderer("pictable", RendererFunc(func(v, val string) (txt string, e error) {
+		txt = `<table class="pictable" bs64dt="` + base.Encode("base64", val) + `" param="` + escAttr(v) + `" style="margin:0 auto;"></table>`
+		return
+	}))
+	for _, name := range []string{"mdtable", "fdtable", "embeddedview"} {
+		class := name
+		RegisterRenderer(name, RendererFunc(func(v, val string) (txt string, e error) {
+			txt = `<div class="` + class + `" dt="` + escAttr(val) + `" param="` + escAttr(v) + `" style="margin:0 auto;"></div>`
+			return
+		}))
+	}
+	RegisterRenderer("tianditu", RendererFunc(func(v, val string) (txt string, e error) {
+		width, height := base.SplitK_V(v, "*")
+		wh := "width: "
+		if len(width) > 0 {
+			wh += escCSS(width)
+		} else {
+			wh += "640"
+		}
+		wh += "px; height: "
+		if len(height) > 0 {
+			wh += escCSS(height)
+		} else {
+			wh += "480"
+		}
+		wh += "px;"
+		txt = `<div id="tianditu" class="tianditu" address="` + escAttr(val) + `" style="` + wh + ` margin:0 auto; border: 1px solid gray;"></div>`
+		return
+	}))
+	RegisterRenderer("appendixes", RendererFunc(func(v, val string) (txt string, e error) {
+		if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
+			txt, e = Appendix2html([]byte(val))
+		}
+		return
+	}))
+	RegisterRenderer("image", RendererFunc(func(v, val string) (txt string, e error) {
+		width, height := base.SplitK_V(v, "*")
+		wh := ""
+		if len(width) > 0 {
+			wh += ` width="` + escAttr(width) + `"`
+		}
+		if len(height) > 0 {
+			wh += ` height="` + escAttr(height) + `"`
+		}
+
+		//{"columns":1,"images":[{"tag":"bigdata.gif","src":"/u?n=illu_d6b34d4d6feedc93f6c614030d18d28f-d0f49d88c867aef9f907e745ba83449b.gif","extension":".gif"}]}
+		if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
+			txt, e = Illustration2html([]byte(val))
+		} else {
+			ss := strings.Split(val, ",")
+			m := len(ss)
+			for j := 0; j < m; j++ {
+				data := ss[j]
+				if len(data) > 0 {
+					if strings.Contains(data, ".") {
+						txt += `<img src="` + escURL(data) + `"` + wh + `>`
+					} else {
+						b, e := base64.StdEncoding.DecodeString(data)
+						if e == nil {
+							var img imageRT
+							e = json.Unmarshal(b, &img)
+							if e == nil {
+								txt += `<img src="` + escURL(img.Url) + `" alt="` + escAttr(img.Tag) + `"` + wh + `>`
+							}
+						}
+					}
+				}
+			}
+		}
+		return
+	}))
+
+	RegisterLever("embeddedview", RendererFunc(func(param, val string) (txt string, e error) {
+		bs64 := base64.StdEncoding.EncodeToString([]byte(val))
+		txt = `<div class="embeddedview" dt="` + bs64 + `" param="` + escAttr(param) + `"></div>`
+		return
+	}))
+	for _, name := range []string{"spinner", "gallery"} { //no value, see AssembleLeverTxt
+		class := name
+		RegisterLever(name, RendererFunc(func(param, val string) (txt string, e error) {
+			txt = `<div class="` + class + `" param="` + escAttr(param) + `"></div>`
+			return
+		}))
+	}
+	RegisterLever("dimensionradio", RendererFunc(func(param, val string) (txt string, e error) {
+		txt = `<div class="dimensionradio" dt="` + escAttr(val) + `" param="` + escAttr(param) + `"></div>`
+		return
+	}))
+}
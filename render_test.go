@@ -0,0 +1,50 @@
+package object
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAssembleLever(t *testing.T) {
+	for lever, want := range map[string]string{
+		"spinner": `<div class="spinner" param="a&#34;b"></div>`,
+		"gallery": `<div class="gallery" param="a&#34;b"></div>`,
+	} {
+		if txt, e := AssembleLeverTxt(lever, `a"b`); e != nil || txt != want {
+			t.Errorf("AssembleLeverTxt(%q) = %q %v, want %q", lever, txt, e, want)
+		}
+	}
+	if _, e := AssembleLeverTxt("nosuch", ""); !errors.Is(e, ErrUnknownRenderer) {
+		t.Errorf("AssembleLeverTxt(nosuch) = %v", e)
+	}
+	if txt, e := AssembleLever("dimensionradio", "p", "<v>"); e != nil || txt != `<div class="dimensionradio" dt="&lt;v&gt;" param="p"></div>` {
+		t.Errorf("AssembleLever(dimensionradio) = %q %v", txt, e)
+	}
+	if txt, e := AssembleLever("", "p", "<v>"); e != nil || txt != "&lt;v&gt;" {
+		t.Errorf("AssembleLever('') = %q %v", txt, e)
+	}
+	if txt, e := AssembleLever("nosuch", "p", "v"); !errors.Is(e, ErrUnknownRenderer) || txt != "v" {
+		t.Errorf("AssembleLever(nosuch) = %q %v", txt, e)
+	}
+}
+
+func TestAssembleRender(t *testing.T) {
+	RegisterRenderer("test_upper", RendererFunc(func(param, val string) (string, error) {
+		return param + ":" + val, nil
+	}))
+	tests := []struct {
+		render, val, txt string
+	}{
+		{"", "<v>", "&lt;v&gt;"},
+		{"decoder", `<"v">`, `<div class="decoder" dt="&lt;&#34;v&#34;&gt;"></div>`},
+		{"test_upper:p", "v", "p:v"},
+	}
+	for _, tt := range tests {
+		if txt, e := AssembleRender(tt.render, tt.val); e != nil || txt != tt.txt {
+			t.Errorf("AssembleRender(%q, %q) = %q %v, want %q", tt.render, tt.val, txt, e, tt.txt)
+		}
+	}
+	if txt, e := AssembleRender("nosuch", "<v>"); !errors.Is(e, ErrUnknownRenderer) || txt != "&lt;v&gt;" {
+		t.Errorf("AssembleRender(nosuch) = %q %v", txt, e)
+	}
+}
@@ -0,0 +1,576 @@
+package object
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// RowT is a row of a table, column name: value.
+type RowT map[string]any
+
+type repoTableT struct {
+	name         string
+	roadmap      []string
+	o            gjson.Result
+	columns      []string
+	mapV         map[string]gjson.Result
+	hierarchical bool
+	autoid       bool
+}
+
+/*
+Repository reads and writes the tables of an extended definition (DefinitionExtend output), sub-objects included.
+id, time_created and time_updated are filled in by the repository, so are parentid, ordinalposition, depth and isleaf of hierarchical objects.
+*/
+type Repository struct {
+	DB         *sql.DB
+	DB_type    int
+	Identifier string
+	tables     map[string]*repoTableT
+	names      []string //collectTables order, parents first
+}
+
+/*
+ListOptionT: Where columns compare by equality, OrderBy columns prefixed by "-" sort descending,
+Limit 0 means no limit.
+*/
+type ListOptionT struct {
+	Where   RowT
+	OrderBy []string
+	Limit   int
+	Offset  int
+}
+
+type queryer interface {
+	execer
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// NewRepository fails on an invalid access rule in a "limit" of the definition, not later on a query.
+func NewRepository(db *sql.DB, db_type int, definition, identifier string) (repo *Repository, e error) {
+	o := gjson.Get(definition, identifier)
+	if !o.Exists() {
+		e = errors.New(identifier + " syntax error!")
+		return
+	}
+	if e = checkAccessLimits(o, []string{identifier}); e != nil {
+		return
+	}
+	repo = &Repository{DB: db, DB_type: db_type, Identifier: identifier, tables: make(map[string]*repoTableT)}
+	tt := []tableT{}
+	collectTables(o, []string{identifier}, &tt)
+	for _, t := range tt {
+		rt := &repoTableT{name: strings.Join(t.roadmap, "_"), roadmap: t.roadmap, o: t.o}
+		rt.columns, rt.mapV = tableColumns(t.o)
+		rt.hierarchical = t.o.Get("self_relationship").String() == "hierarchical"
+		rt.autoid = t.o.Get("type").String() != "object_extension"
+		repo.tables[rt.name] = rt
+		repo.names = append(repo.names, rt.name)
+	}
+	return
+}
+
+func (repo *Repository) table(tablename string) (rt *repoTableT, e error) {
+	rt, ok := repo.tables[tablename]
+	if !ok {
+		e = errors.New(tablename + " is not a table of " + repo.Identifier + "!")
+	}
+	return
+}
+
+func (rt *repoTableT) hasColumn(name string) bool {
+	_, ok := rt.mapV[name]
+	return ok
+}
+
+// checkColumns: every key of row is a column of the table.
+func (rt *repoTableT) checkColumns(row RowT) (e error) {
+	for _, k := range sortedKeys(row) {
+		if !rt.hasColumn(k) {
+			e = errors.New(rt.name + "." + k + " not exists!")
+			return
+		}
+	}
+	return
+}
+
+func (repo *Repository) ident(name string) string {
+	return sqlIdentifier(name, repo.DB_type)
+}
+
+// Insert adds row to tablename and returns its id.
+func (repo *Repository) Insert(tablename string, row RowT) (id int64, e error) {
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = rt.checkColumns(row); e != nil {
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		id, err = repo.insert(tx, rt, row)
+		return
+	})
+	return
+}
+
+func (repo *Repository) insert(db queryer, rt *repoTableT, row RowT) (id int64, e error) {
+	values := RowT{}
+	for k, v := range row {
+		values[k] = v
+	}
+	if rt.autoid {
+		delete(values, "id")
+	}
+	for _, k := range []string{"time_created", "time_updated"} {
+		delete(values, k)
+	}
+	if rt.hierarchical {
+		parentid := toInt64(values["parentid"])
+		depth := int64(0)
+		if parentid > 0 {
+			if e = db.QueryRow("SELECT depth FROM "+repo.ident(rt.name)+" WHERE id="+repo.placeholder(1), parentid).Scan(&depth); e != nil {
+				e = errors.New(rt.name + " parent " + strconv.FormatInt(parentid, 10) + ": " + e.Error())
+				return
+			}
+			depth++
+		}
+		values["parentid"] = parentid
+		values["depth"] = depth
+		values["isleaf"] = 1
+		if toInt64(values["ordinalposition"]) == 0 {
+			var ordinal int64
+			if ordinal, e = repo.nextOrdinal(db, rt, parentid); e != nil {
+				return
+			}
+			values["ordinalposition"] = ordinal
+		}
+	}
+	sa := sqlArgsT{db_type: repo.DB_type}
+	cc, vv := []string{}, []string{}
+	for _, k := range sortedKeys(values) {
+		cc = append(cc, repo.ident(k))
+		vv = append(vv, sa.bind(values[k]))
+	}
+	for _, k := range []string{"time_created", "time_updated"} {
+		if rt.hasColumn(k) {
+			cc = append(cc, repo.ident(k))
+			vv = append(vv, sqlNow(repo.DB_type))
+		}
+	}
+	query := "INSERT INTO " + repo.ident(rt.name) + "(" + strings.Join(cc, ",") + ") VALUES(" + strings.Join(vv, ",") + ")"
+	if !rt.autoid {
+		id = toInt64(values["id"])
+		_, e = db.Exec(query, sa.args...)
+	} else if repo.DB_type == PostgreSQL {
+		e = db.QueryRow(query+" RETURNING id", sa.args...).Scan(&id)
+	} else {
+		var rs sql.Result
+		if rs, e = db.Exec(query, sa.args...); e == nil {
+			id, e = rs.LastInsertId()
+		}
+	}
+	if e == nil && rt.hierarchical {
+		if parentid := toInt64(values["parentid"]); parentid > 0 {
+			_, e = db.Exec("UPDATE "+repo.ident(rt.name)+" SET isleaf=0 WHERE id="+repo.placeholder(1), parentid)
+		}
+	}
+	return
+}
+
+// Get reads the row of tablename by id, sql.ErrNoRows if there is none.
+func (repo *Repository) Get(tablename string, id int64) (row RowT, e error) {
+	rows, err := repo.List(tablename, ListOptionT{Where: RowT{"id": id}, Limit: 1})
+	if err != nil {
+		e = err
+		return
+	}
+	if len(rows) == 0 {
+		e = sql.ErrNoRows
+		return
+	}
+	row = rows[0]
+	return
+}
+
+/*
+Update sets the columns of row on the row of tablename by id, time_updated included.
+A changed parentid moves the row with its descendants under the new parent.
+*/
+func (repo *Repository) Update(tablename string, id int64, row RowT) (affected int64, e error) {
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = rt.checkColumns(row); e != nil {
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		affected, err = repo.update(tx, rt, id, row)
+		return
+	})
+	return
+}
+
+func (repo *Repository) update(db queryer, rt *repoTableT, id int64, row RowT) (affected int64, e error) {
+	values := RowT{}
+	for k, v := range row {
+		switch k {
+		case "id", "time_created", "time_updated":
+		case "depth", "isleaf":
+			if !rt.hierarchical {
+				values[k] = v
+			}
+		default:
+			values[k] = v
+		}
+	}
+	if parentid, ok := values["parentid"]; ok && rt.hierarchical {
+		delete(values, "parentid")
+		if e = repo.move(db, rt, id, toInt64(parentid)); e != nil {
+			return
+		}
+	}
+	sa := sqlArgsT{db_type: repo.DB_type}
+	ss := []string{}
+	for _, k := range sortedKeys(values) {
+		ss = append(ss, repo.ident(k)+"="+sa.bind(values[k]))
+	}
+	if rt.hasColumn("time_updated") {
+		ss = append(ss, repo.ident("time_updated")+"="+sqlNow(repo.DB_type))
+	}
+	if len(ss) > 0 {
+		query := "UPDATE " + repo.ident(rt.name) + " SET " + strings.Join(ss, ",") + " WHERE id=" + sa.bind(id)
+		affected, e = execSQL(db, query, sa.args...)
+	}
+	return
+}
+
+// move: id with its descendants under parentid, at the end of the new siblings.
+func (repo *Repository) move(db queryer, rt *repoTableT, id, parentid int64) (e error) {
+	table := repo.ident(rt.name)
+	var oldparent, depth int64
+	if e = db.QueryRow("SELECT parentid,depth FROM "+table+" WHERE id="+repo.placeholder(1), id).Scan(&oldparent, &depth); e != nil {
+		return
+	}
+	if oldparent == parentid {
+		return
+	}
+	descendants, err := repo.descendantIDs(db, rt, id)
+	if err != nil {
+		e = err
+		return
+	}
+	newdepth := int64(0)
+	if parentid > 0 {
+		if parentid == id {
+			e = errors.New(rt.name + ": can not move " + strconv.FormatInt(id, 10) + " under itself!")
+			return
+		}
+		for _, d := range descendants {
+			if d == parentid {
+				e = errors.New(rt.name + ": can not move " + strconv.FormatInt(id, 10) + " under its descendant!")
+				return
+			}
+		}
+		if e = db.QueryRow("SELECT depth FROM "+table+" WHERE id="+repo.placeholder(1), parentid).Scan(&newdepth); e != nil {
+			return
+		}
+		newdepth++
+	}
+	ordinal, err := repo.nextOrdinal(db, rt, parentid)
+	if err != nil {
+		e = err
+		return
+	}
+	sa := sqlArgsT{db_type: repo.DB_type}
+	query := "UPDATE " + table + " SET parentid=" + sa.bind(parentid) + ",ordinalposition=" + sa.bind(ordinal) + ",depth=" + sa.bind(newdepth) + " WHERE id=" + sa.bind(id)
+	if _, e = db.Exec(query, sa.args...); e != nil {
+		return
+	}
+	if delta := newdepth - depth; delta != 0 {
+		for _, d := range descendants {
+			if _, e = db.Exec("UPDATE "+table+" SET depth=depth+"+repo.placeholder(1)+" WHERE id="+repo.placeholder(2), delta, d); e != nil {
+				return
+			}
+		}
+	}
+	if parentid > 0 {
+		if _, e = db.Exec("UPDATE "+table+" SET isleaf=0 WHERE id="+repo.placeholder(1), parentid); e != nil {
+			return
+		}
+	}
+	e = repo.refreshLeaf(db, rt, oldparent)
+	return
+}
+
+// Delete removes the row of tablename by id with its rows in the sub-object tables.
+// A hierarchical row with children is not deleted.
+func (repo *Repository) Delete(tablename string, id int64) (affected int64, e error) {
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		affected, err = repo.delete(tx, rt, id)
+		return
+	})
+	return
+}
+
+func (repo *Repository) delete(db queryer, rt *repoTableT, id int64) (affected int64, e error) {
+	table := repo.ident(rt.name)
+	var parentid int64
+	if rt.hierarchical {
+		var children int64
+		if e = db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE parentid="+repo.placeholder(1), id).Scan(&children); e != nil {
+			return
+		}
+		if children > 0 {
+			e = errors.New(rt.name + " " + strconv.FormatInt(id, 10) + " has children!")
+			return
+		}
+		if e = db.QueryRow("SELECT parentid FROM "+table+" WHERE id="+repo.placeholder(1), id).Scan(&parentid); e != nil {
+			return
+		}
+	}
+	if e = repo.deleteSubrows(db, rt, []int64{id}); e != nil {
+		return
+	}
+	if affected, e = execSQL(db, "DELETE FROM "+table+" WHERE id="+repo.placeholder(1), id); e != nil {
+		return
+	}
+	if rt.hierarchical {
+		e = repo.refreshLeaf(db, rt, parentid)
+	}
+	return
+}
+
+// deleteSubrows: the rows of the sub-object tables of rt (the _languages table included) belonging to ids.
+func (repo *Repository) deleteSubrows(db queryer, rt *repoTableT, ids []int64) (e error) {
+	fk := rt.name + "_id"
+	for i := len(repo.names) - 1; i >= 0; i-- {
+		sub := repo.tables[repo.names[i]]
+		if len(sub.roadmap) > len(rt.roadmap) && strings.HasPrefix(sub.name, rt.name+"_") && sub.hasColumn(fk) {
+			for _, id := range ids {
+				if _, e = db.Exec("DELETE FROM "+repo.ident(sub.name)+" WHERE "+repo.ident(fk)+"="+repo.placeholder(1), id); e != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// List reads the rows of tablename, hierarchical tables sort by parentid,ordinalposition and others by id unless OrderBy is set.
+func (repo *Repository) List(tablename string, opt ListOptionT) (rows []RowT, e error) {
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	sa := sqlArgsT{db_type: repo.DB_type}
+	where, err := repo.where(rt, opt.Where, &sa)
+	if err != nil {
+		e = err
+		return
+	}
+	cc := []string{}
+	for _, c := range rt.columns {
+		cc = append(cc, repo.ident(c))
+	}
+	query := "SELECT " + strings.Join(cc, ",") + " FROM " + repo.ident(rt.name) + where
+	orderby := opt.OrderBy
+	if len(orderby) == 0 {
+		if rt.hierarchical {
+			orderby = []string{"parentid", "ordinalposition"}
+		} else if rt.hasColumn("id") {
+			orderby = []string{"id"}
+		}
+	}
+	oo := []string{}
+	for _, ob := range orderby {
+		column, desc := strings.TrimPrefix(ob, "-"), strings.HasPrefix(ob, "-")
+		if !rt.hasColumn(column) {
+			e = errors.New(rt.name + "." + column + " not exists!")
+			return
+		}
+		if desc {
+			oo = append(oo, repo.ident(column)+" DESC")
+		} else {
+			oo = append(oo, repo.ident(column))
+		}
+	}
+	if len(oo) > 0 {
+		query += " ORDER BY " + strings.Join(oo, ",")
+	}
+	if opt.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(opt.Limit)
+		if opt.Offset > 0 {
+			query += " OFFSET " + strconv.Itoa(opt.Offset)
+		}
+	}
+	rows, e = repo.query(repo.DB, rt, query, sa.args...)
+	return
+}
+
+// Count counts the rows of tablename matching where.
+func (repo *Repository) Count(tablename string, where RowT) (n int64, e error) {
+	rt, err := repo.table(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	sa := sqlArgsT{db_type: repo.DB_type}
+	w, err := repo.where(rt, where, &sa)
+	if err != nil {
+		e = err
+		return
+	}
+	e = repo.DB.QueryRow("SELECT COUNT(*) FROM "+repo.ident(rt.name)+w, sa.args...).Scan(&n)
+	return
+}
+
+func (repo *Repository) where(rt *repoTableT, where RowT, sa *sqlArgsT) (w string, e error) {
+	if e = rt.checkColumns(where); e != nil {
+		return
+	}
+	ww := []string{}
+	for _, k := range sortedKeys(where) {
+		ww = append(ww, repo.ident(k)+"="+sa.bind(where[k]))
+	}
+	if len(ww) > 0 {
+		w = " WHERE " + strings.Join(ww, " AND ")
+	}
+	return
+}
+
+// query scans the rows, text read as []byte comes back as string.
+func (repo *Repository) query(db queryer, rt *repoTableT, query string, args ...any) (rows []RowT, e error) {
+	rs, err := db.Query(query, args...)
+	if err != nil {
+		e = err
+		return
+	}
+	defer rs.Close()
+	columns, err := rs.Columns()
+	if err != nil {
+		e = err
+		return
+	}
+	for rs.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if e = rs.Scan(pointers...); e != nil {
+			return
+		}
+		row := RowT{}
+		for i, c := range columns {
+			if bb, ok := values[i].([]byte); ok && rt.mapV[c].Get("type").String() != "blob" {
+				row[c] = string(bb)
+			} else {
+				row[c] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	e = rs.Err()
+	return
+}
+
+func (repo *Repository) nextOrdinal(db queryer, rt *repoTableT, parentid int64) (ordinal int64, e error) {
+	var max sql.NullInt64
+	e = db.QueryRow("SELECT MAX(ordinalposition) FROM "+repo.ident(rt.name)+" WHERE parentid="+repo.placeholder(1), parentid).Scan(&max)
+	ordinal = max.Int64 + 1
+	return
+}
+
+// refreshLeaf: isleaf of id from whether it has children.
+func (repo *Repository) refreshLeaf(db queryer, rt *repoTableT, id int64) (e error) {
+	if id > 0 {
+		table := repo.ident(rt.name)
+		query := "UPDATE " + table + " SET isleaf=CASE WHEN EXISTS(SELECT 1 FROM " + table + " c WHERE c.parentid=" + repo.placeholder(1) + ") THEN 0 ELSE 1 END WHERE id=" + repo.placeholder(2)
+		_, e = db.Exec(query, id, id)
+	}
+	return
+}
+
+// descendantIDs: the ids below id, breadth first.
+func (repo *Repository) descendantIDs(db queryer, rt *repoTableT, id int64) (ids []int64, e error) {
+	query := "SELECT id FROM " + repo.ident(rt.name) + " WHERE parentid=" + repo.placeholder(1) + " ORDER BY ordinalposition"
+	queue := []int64{id}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		rs, err := db.Query(query, node)
+		if err != nil {
+			e = err
+			return
+		}
+		children := []int64{}
+		for rs.Next() {
+			var child int64
+			if e = rs.Scan(&child); e != nil {
+				rs.Close()
+				return
+			}
+			children = append(children, child)
+		}
+		rs.Close()
+		ids = append(ids, children...)
+		queue = append(queue, children...)
+	}
+	return
+}
+
+// placeholder: the n-th placeholder of a statement.
+func (repo *Repository) placeholder(n int) string {
+	if repo.DB_type == PostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (repo *Repository) inTx(f func(tx *sql.Tx) error) (e error) {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		e = err
+		return
+	}
+	if e = f(tx); e == nil {
+		e = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	return
+}
+
+func toInt64(v any) (i int64) {
+	switch n := v.(type) {
+	case int:
+		i = int64(n)
+	case int32:
+		i = int64(n)
+	case int64:
+		i = n
+	case float64:
+		i = int64(n)
+	case string:
+		i = int64(base.Str2int(n))
+	case []byte:
+		i = int64(base.Str2int(string(n)))
+	}
+	return
+}
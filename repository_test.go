@@ -0,0 +1,170 @@
+package object
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/svcbase/base"
+)
+
+const repositoryDefinition = `{"category":{"type":"object","language":"multiple","self_relationship":"hierarchical",
+	"id":{"type":"int"},"name":{"type":"string","size":50,"language_adaptive":true},"note":{"type":"string","size":50},
+	"item":{"type":"object","id":{"type":"int"},"title":{"type":"string","size":50},
+		"part":{"type":"object","id":{"type":"int"},"label":{"type":"string","size":20}}}}}`
+
+// openTestRepository: the extended definition of identifier installed in a SQLite test database.
+func openTestRepository(t *testing.T, definition, identifier string) (repo *Repository) {
+	t.Helper()
+	extended, _, e := DefinitionExtend([]byte(definition), identifier, "", "", "", true)
+	if e != nil {
+		t.Fatal(e)
+	}
+	db := openTestDB(t)
+	if _, e = NewInstaller(db, base.SQLite, "tester").Install(extended, identifier); e != nil {
+		t.Fatal(e)
+	}
+	if repo, e = NewRepository(db, base.SQLite, extended, identifier); e != nil {
+		t.Fatal(e)
+	}
+	return
+}
+
+// treeState: id: parentid,depth,isleaf,ordinalposition of every row of a hierarchical table.
+func treeState(t *testing.T, repo *Repository, tablename string) (state map[int64][4]int64) {
+	t.Helper()
+	rows, e := repo.List(tablename, ListOptionT{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	state = make(map[int64][4]int64)
+	for _, row := range rows {
+		state[toInt64(row["id"])] = [4]int64{toInt64(row["parentid"]), toInt64(row["depth"]), toInt64(row["isleaf"]), toInt64(row["ordinalposition"])}
+	}
+	return
+}
+
+func mustInsert(t *testing.T, repo *Repository, tablename string, row RowT) (id int64) {
+	t.Helper()
+	id, e := repo.Insert(tablename, row)
+	if e != nil {
+		t.Fatalf("insert %s %v: %v", tablename, row, e)
+	}
+	return
+}
+
+func TestRepositoryHierarchy(t *testing.T) {
+	repo := openTestRepository(t, repositoryDefinition, "category")
+	root := mustInsert(t, repo, "category", RowT{"name": "root"})
+	a := mustInsert(t, repo, "category", RowT{"name": "a", "parentid": root})
+	b := mustInsert(t, repo, "category", RowT{"name": "b", "parentid": root, "depth": 7, "isleaf": 0})
+	a1 := mustInsert(t, repo, "category", RowT{"name": "a1", "parentid": a})
+	want := map[int64][4]int64{
+		root: {0, 0, 0, 1},
+		a:    {root, 1, 0, 1},
+		b:    {root, 1, 1, 2},
+		a1:   {a, 2, 1, 1},
+	}
+	if got := treeState(t, repo, "category"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after insert = %v, want %v", got, want)
+	}
+	if _, e := repo.Insert("category", RowT{"name": "orphan", "parentid": 99}); e == nil {
+		t.Error("insert under a missing parent: no error")
+	}
+
+	if _, e := repo.Update("category", a, RowT{"parentid": b, "depth": 5, "note": "moved"}); e != nil {
+		t.Fatal(e)
+	}
+	want = map[int64][4]int64{
+		root: {0, 0, 0, 1},
+		a:    {b, 2, 0, 1},
+		b:    {root, 1, 0, 2},
+		a1:   {a, 3, 1, 1},
+	}
+	if got := treeState(t, repo, "category"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after update = %v, want %v", got, want)
+	}
+	if row, e := repo.Get("category", a); e != nil || row["note"] != "moved" {
+		t.Errorf("Get(a) = %v, %v", row, e)
+	}
+	if _, e := repo.Update("category", a, RowT{"parentid": a1}); e == nil {
+		t.Error("move under its descendant: no error")
+	}
+
+	if _, e := repo.Delete("category", a); e == nil {
+		t.Error("delete with children: no error")
+	}
+	if n, e := repo.Delete("category", a1); e != nil || n != 1 {
+		t.Fatalf("Delete(a1) = %d, %v", n, e)
+	}
+	if got := treeState(t, repo, "category")[a]; got[2] != 1 {
+		t.Errorf("a after its last child is deleted: isleaf %d, want 1", got[2])
+	}
+}
+
+func TestRepositoryCascadeDelete(t *testing.T) {
+	repo := openTestRepository(t, repositoryDefinition, "category")
+	c1 := mustInsert(t, repo, "category", RowT{"name": "c1"})
+	c2 := mustInsert(t, repo, "category", RowT{"name": "c2"})
+	i1 := mustInsert(t, repo, "category_item", RowT{"category_id": c1, "title": "i1"})
+	i2 := mustInsert(t, repo, "category_item", RowT{"category_id": c2, "title": "i2"})
+	mustInsert(t, repo, "category_item_part", RowT{"category_id": c1, "category_item_id": i1, "label": "p1"})
+	mustInsert(t, repo, "category_item_part", RowT{"category_id": c2, "category_item_id": i2, "label": "p2"})
+	mustInsert(t, repo, "category_languages", RowT{"category_id": c1, "language_id": 2, "name": "c1 zh"})
+
+	if n, e := repo.Delete("category", c1); e != nil || n != 1 {
+		t.Fatalf("Delete(c1) = %d, %v", n, e)
+	}
+	for _, tt := range []struct {
+		tablename string
+		want      int64
+	}{
+		{"category", 1},
+		{"category_item", 1},
+		{"category_item_part", 1},
+		{"category_languages", 0},
+	} {
+		if n, e := repo.Count(tt.tablename, nil); e != nil || n != tt.want {
+			t.Errorf("Count(%s) = %d, %v, want %d", tt.tablename, n, e, tt.want)
+		}
+	}
+	if n, e := repo.Count("category_item", RowT{"category_id": c2}); e != nil || n != 1 {
+		t.Errorf("items of c2 = %d, %v, want 1", n, e)
+	}
+	if _, e := repo.Get("category", c1); e != sql.ErrNoRows {
+		t.Errorf("Get(c1) error = %v, want sql.ErrNoRows", e)
+	}
+}
+
+func TestRepositoryList(t *testing.T) {
+	repo := openTestRepository(t, repositoryDefinition, "category")
+	for _, name := range []string{"b", "c", "a"} {
+		mustInsert(t, repo, "category", RowT{"name": name, "note": "x"})
+	}
+	rows, e := repo.List("category", ListOptionT{Where: RowT{"note": "x"}, OrderBy: []string{"-name"}, Limit: 2, Offset: 1})
+	if e != nil {
+		t.Fatal(e)
+	}
+	names := []any{}
+	for _, row := range rows {
+		names = append(names, row["name"])
+	}
+	if want := []any{"b", "a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	for _, tt := range []struct {
+		tablename string
+		opt       ListOptionT
+	}{
+		{"book", ListOptionT{}},
+		{"category", ListOptionT{Where: RowT{"missing": 1}}},
+		{"category", ListOptionT{OrderBy: []string{"missing"}}},
+	} {
+		if _, e = repo.List(tt.tablename, tt.opt); e == nil {
+			t.Errorf("List(%s, %v): no error", tt.tablename, tt.opt)
+		}
+	}
+	if _, e = repo.Insert("category", RowT{"missing": 1}); e == nil {
+		t.Error("insert of a missing column: no error")
+	}
+}
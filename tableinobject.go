@@ -17,12 +17,24 @@ type CodesetPropertyT struct {
 	Propertycaption string
 }
 
+/*
+RelationT is an edge of the dependency graph: table From depends on table To.
+Kind: parent (sub-object of To), relation (object_relation), extension (object_extension), codeset.
+*/
+type RelationT struct {
+	From     string
+	To       string
+	Kind     string
+	Property string
+}
+
 type TableInObject struct {
 	Identifier       string
 	Tables           []string
 	Objects          []string
 	Codesets         []string
 	CodesetinObjects map[string][]CodesetPropertyT
+	Relations        []RelationT
 }
 
 func (tio *TableInObject) ParseO(o *gjson.Result) (e error) {
@@ -55,6 +67,16 @@ func (tio *TableInObject) getTable(o *gjson.Result, roadmap []string) {
 			tio.Objects = append(tio.Objects, table)
 		}
 		caption := o.Get("caption").String()
+		if n := len(roadmap); n > 1 {
+			parent := strings.Join(roadmap[0:n-1], "_")
+			tio.addRelation(RelationT{table, parent, "parent", parent + "_id"})
+		}
+		if relation := o.Get("relation").String(); len(relation) > 0 && o_type == "object_relation" {
+			tio.addRelation(RelationT{table, relation, "relation", relation + "_id"})
+		}
+		if extension := o.Get("extension").String(); len(extension) > 0 { //object_extension, <identifier>_languages
+			tio.addRelation(RelationT{table, extension, "extension", extension + "_id"})
+		}
 		o.ForEach(func(k, v gjson.Result) bool {
 			key := k.String()
 			if v.Type.String() == "JSON" {
@@ -73,8 +95,9 @@ func (tio *TableInObject) getTable(o *gjson.Result, roadmap []string) {
 						}
 						cp := CodesetPropertyT{Tablename: table, Objectcaption: caption, Propertyname: key, Propertytype: otype, Propertycaption: v.Get("caption").String()}
 
+						tio.addRelation(RelationT{table, codeset, "codeset", key})
 						if cio, ok := tio.CodesetinObjects[codeset]; ok {
-							cio = append(cio, cp)
+							tio.CodesetinObjects[codeset] = append(cio, cp)
 						} else {
 							tio.CodesetinObjects[codeset] = []CodesetPropertyT{cp}
 						}
@@ -95,3 +118,181 @@ func (tio *TableInObject) CodesetRelatedTables(codeset string) (codesetntables [
 	codesetntables = tio.CodesetinObjects[codeset]
 	return
 }
+
+func (tio *TableInObject) addRelation(r RelationT) {
+	for _, rr := range tio.Relations {
+		if rr == r {
+			return
+		}
+	}
+	tio.Relations = append(tio.Relations, r)
+}
+
+// Merge adds the tables and relations of other objects, for a graph over a whole set of definitions.
+func (tio *TableInObject) Merge(others ...*TableInObject) {
+	if tio.CodesetinObjects == nil {
+		tio.CodesetinObjects = make(map[string][]CodesetPropertyT)
+	}
+	for _, other := range others {
+		for _, table := range other.Tables {
+			if exists, _ := base.In_array(table, tio.Tables); !exists {
+				tio.Tables = append(tio.Tables, table)
+			}
+		}
+		for _, table := range other.Objects {
+			if exists, _ := base.In_array(table, tio.Objects); !exists {
+				tio.Objects = append(tio.Objects, table)
+			}
+		}
+		for _, codeset := range other.Codesets {
+			if exists, _ := base.In_array(codeset, tio.Codesets); !exists {
+				tio.Codesets = append(tio.Codesets, codeset)
+			}
+		}
+		for codeset, cps := range other.CodesetinObjects {
+			tio.CodesetinObjects[codeset] = append(tio.CodesetinObjects[codeset], cps...)
+		}
+		for _, r := range other.Relations {
+			tio.addRelation(r)
+		}
+	}
+}
+
+// nodes: the tables and every table a relation points to, once each, sorted.
+func (tio *TableInObject) nodes() (nodes []string) {
+	tables := append([]string{}, tio.Tables...) //an object using its own table as options is in Tables twice
+	for _, r := range tio.Relations {
+		tables = append(tables, r.From, r.To)
+	}
+	for _, table := range tables {
+		if exists, _ := base.In_array(table, nodes); !exists {
+			nodes = append(nodes, table)
+		}
+	}
+	sort.Strings(nodes)
+	return
+}
+
+// Dependencies: the tables table depends on directly.
+func (tio *TableInObject) Dependencies(table string) (tables []string) {
+	for _, r := range tio.Relations {
+		if r.From == table && r.To != table {
+			if exists, _ := base.In_array(r.To, tables); !exists {
+				tables = append(tables, r.To)
+			}
+		}
+	}
+	return
+}
+
+// TopologicalOrder: every table after the tables it depends on, an error if the relations have a cycle.
+func (tio *TableInObject) TopologicalOrder() (tables []string, e error) {
+	nodes := tio.nodes()
+	indegree := make(map[string]int)
+	for _, node := range nodes {
+		indegree[node] = len(tio.Dependencies(node))
+	}
+	done := make(map[string]bool)
+	for len(tables) < len(nodes) {
+		ready := []string{}
+		for _, node := range nodes {
+			if !done[node] && indegree[node] == 0 {
+				ready = append(ready, node)
+			}
+		}
+		if len(ready) == 0 {
+			cc := []string{}
+			for _, cycle := range tio.Cycles() {
+				cc = append(cc, strings.Join(cycle, "->"))
+			}
+			e = errors.New("relation cycle: " + strings.Join(cc, "; "))
+			return
+		}
+		for _, node := range ready {
+			done[node] = true
+			tables = append(tables, node)
+			for _, dependent := range tio.Dependents(node) {
+				indegree[dependent]--
+			}
+		}
+	}
+	return
+}
+
+// Dependents: the tables depending on table directly.
+func (tio *TableInObject) Dependents(table string) (tables []string) {
+	for _, r := range tio.Relations {
+		if r.To == table && r.From != table {
+			if exists, _ := base.In_array(r.From, tables); !exists {
+				tables = append(tables, r.From)
+			}
+		}
+	}
+	return
+}
+
+// Cycles: groups of tables depending on each other (Tarjan). A self relation is no cycle, as for TopologicalOrder.
+func (tio *TableInObject) Cycles() (cycles [][]string) {
+	index, lowlink := make(map[string]int), make(map[string]int)
+	onstack := make(map[string]bool)
+	stack := []string{}
+	var strongconnect func(node string)
+	strongconnect = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onstack[node] = true
+		for _, next := range tio.Dependencies(node) {
+			if _, visited := index[next]; !visited {
+				strongconnect(next)
+				if lowlink[next] < lowlink[node] {
+					lowlink[node] = lowlink[next]
+				}
+			} else if onstack[next] && index[next] < lowlink[node] {
+				lowlink[node] = index[next]
+			}
+		}
+		if lowlink[node] == index[node] {
+			component := []string{}
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onstack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			if len(component) > 1 {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+	for _, node := range tio.nodes() {
+		if _, visited := index[node]; !visited {
+			strongconnect(node)
+		}
+	}
+	return
+}
+
+// DropImpact answers what breaks if table is dropped: the relations pointing to it, directly or through dependent tables.
+func (tio *TableInObject) DropImpact(table string) (broken []RelationT) {
+	visited := map[string]bool{table: true}
+	queue := []string{table}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, r := range tio.Relations {
+			if r.To == node && r.From != node {
+				broken = append(broken, r)
+				if !visited[r.From] {
+					visited[r.From] = true
+					queue = append(queue, r.From)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+const graphDefinition = `{"type":"object","caption":"article",
+	"title":{"type":"string"},"status_id":{"type":"int","options":"status"},"region_code":{"type":"string","codeset":"region"},
+	"parent_id":{"type":"int","options":"article"},
+	"comments":{"type":"object","user_id":{"type":"int","options":"user"},
+		"likes":{"type":"object_relation","relation":"user"}},
+	"languages":{"type":"object_extension","extension":"language","title":{"type":"string"}}}`
+
+func newGraph(t *testing.T, identifier, definition string) (tio *TableInObject) {
+	t.Helper()
+	tio = &TableInObject{Identifier: identifier}
+	if e := tio.Parse(definition); e != nil {
+		t.Fatal(e)
+	}
+	return
+}
+
+func TestTableInObjectRelations(t *testing.T) {
+	tio := newGraph(t, "article", graphDefinition)
+	want := []RelationT{
+		{"article", "status", "codeset", "status_id"},
+		{"article", "region", "codeset", "region_code"},
+		{"article", "article", "codeset", "parent_id"},
+		{"article_comments", "article", "parent", "article_id"},
+		{"article_comments", "user", "codeset", "user_id"},
+		{"article_comments_likes", "article_comments", "parent", "article_comments_id"},
+		{"article_comments_likes", "user", "relation", "user_id"},
+		{"article_languages", "article", "parent", "article_id"},
+		{"article_languages", "language", "extension", "language_id"},
+	}
+	if !reflect.DeepEqual(tio.Relations, want) {
+		t.Errorf("Relations =\n%v\nwant\n%v", tio.Relations, want)
+	}
+	if got := tio.Tables; !reflect.DeepEqual(got, []string{"article", "article_comments", "article_comments_likes", "article_languages", "article", "region", "status", "user"}) {
+		t.Errorf("Tables = %q", got)
+	}
+	if got := tio.CodesetRelatedTables("region"); len(got) != 1 || got[0].Propertyname != "region_code" || got[0].Propertytype != "code" {
+		t.Errorf("CodesetRelatedTables(region) = %+v", got)
+	}
+	if got, want := tio.Dependencies("article_comments"), []string{"article", "user"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependencies = %q, want %q", got, want)
+	}
+	if got, want := tio.Dependents("article"), []string{"article_comments", "article_languages"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependents = %q, want %q", got, want)
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tio := newGraph(t, "article", graphDefinition)
+	tables, e := tio.TopologicalOrder()
+	want := []string{"language", "region", "status", "user", "article", "article_comments", "article_languages", "article_comments_likes"}
+	if e != nil || !reflect.DeepEqual(tables, want) {
+		t.Errorf("TopologicalOrder = %q %v, want %q", tables, e, want)
+	}
+	if cycles := tio.Cycles(); len(cycles) != 0 { //article options article is no cycle
+		t.Errorf("Cycles = %q", cycles)
+	}
+
+	tio.Merge(newGraph(t, "user", `{"type":"object","favorite_id":{"type":"int","options":"article_comments"}}`))
+	want2 := [][]string{{"article_comments", "user"}}
+	if cycles := tio.Cycles(); !reflect.DeepEqual(cycles, want2) {
+		t.Errorf("Cycles = %q, want %q", cycles, want2)
+	}
+	if _, e = tio.TopologicalOrder(); e == nil || e.Error() != "relation cycle: article_comments->user" {
+		t.Errorf("TopologicalOrder with a cycle: %v", e)
+	}
+}
+
+func TestDropImpact(t *testing.T) {
+	tio := newGraph(t, "article", graphDefinition)
+	want := []RelationT{
+		{"article_comments", "user", "codeset", "user_id"},
+		{"article_comments_likes", "user", "relation", "user_id"},
+		{"article_comments_likes", "article_comments", "parent", "article_comments_id"},
+	}
+	if got := tio.DropImpact("user"); !reflect.DeepEqual(got, want) {
+		t.Errorf("DropImpact(user) =\n%v\nwant\n%v", got, want)
+	}
+	if got := tio.DropImpact("article_languages"); len(got) != 0 {
+		t.Errorf("DropImpact(article_languages) = %v", got)
+	}
+	if got := tio.DropImpact("language"); len(got) != 1 || got[0].Kind != "extension" {
+		t.Errorf("DropImpact(language) = %v", got)
+	}
+}
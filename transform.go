@@ -0,0 +1,242 @@
+package object
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// TransformContextT is what a transform knows besides its data.
+type TransformContextT struct {
+	Property string            //the property transformed, fill_templet replaces [property]
+	Session  map[string]string //session values: dateformat_preferred, timeformat_preferred, clientlanguage_id
+}
+
+// TransformParamParser turns the text after "#" into the parameter of a transform, "" when there is none.
+type TransformParamParser func(param string) (any, error)
+
+// TransformFunc transforms data with the parameter its parser returned.
+type TransformFunc func(ctx *TransformContextT, data string, param any) (string, error)
+
+type transformT struct {
+	parse     TransformParamParser
+	transform TransformFunc
+}
+
+var (
+	transformsMutex sync.RWMutex
+	transforms      = make(map[string]transformT)
+)
+
+// RegisterTransform adds (or replaces) the transform name, parse nil takes the parameter as a string.
+func RegisterTransform(name string, parse TransformParamParser, transform TransformFunc) (e error) {
+	if len(name) == 0 || strings.ContainsAny(name, "#|") || transform == nil {
+		e = errors.New("transform " + name + " syntax error!")
+		return
+	}
+	if parse == nil {
+		parse = stringParam
+	}
+	transformsMutex.Lock()
+	transforms[name] = transformT{parse, transform}
+	transformsMutex.Unlock()
+	return
+}
+
+// Transforms: the registered transform names.
+func Transforms() (names []string) {
+	transformsMutex.RLock()
+	names = sortedKeys(transforms)
+	transformsMutex.RUnlock()
+	return
+}
+
+func stringParam(param string) (any, error) {
+	return param, nil
+}
+
+func noParam(param string) (any, error) {
+	if len(param) > 0 {
+		return nil, errors.New("no parameter expected")
+	}
+	return nil, nil
+}
+
+func intParam(param string) (any, error) {
+	return base.Str2int(param), nil
+}
+
+// replacePairT: src/des of "replace#src/des;src1/des1".
+type replacePairT struct {
+	src, des string
+}
+
+func replaceParam(param string) (any, error) {
+	pairs := []replacePairT{}
+	for _, sd := range strings.Split(param, ";") {
+		s_d := strings.Split(sd, "/")
+		if len(s_d) == 2 {
+			pairs = append(pairs, replacePairT{s_d[0], s_d[1]})
+		}
+	}
+	return pairs, nil
+}
+
+/*
+ApplyTransform applies transform to data, steps chained by "|" each one name#param:
+"data_extract#images.src|cut_left#20". An unknown name or a bad parameter is an error.
+A "|" not followed by a registered name belongs to the parameter ("replace#|/,"), "\|" is always one.
+*/
+func ApplyTransform(property, data, transform, sessionvalues string) (dt string, e error) {
+	dt = data
+	if len(transform) == 0 {
+		return
+	}
+	ctx := &TransformContextT{Property: property, Session: base.String2MapOper(sessionvalues, "&", "=")}
+	for _, step := range splitTransform(transform) {
+		name, param, _ := strings.Cut(step, "#")
+		transformsMutex.RLock()
+		t, ok := transforms[name]
+		transformsMutex.RUnlock()
+		if !ok {
+			e = errors.New("unknown transform: " + name)
+			return
+		}
+		p, err := t.parse(param)
+		if err != nil {
+			e = errors.New("transform " + name + ": " + err.Error())
+			return
+		}
+		if dt, e = t.transform(ctx, dt, p); e != nil {
+			e = errors.New("transform " + name + ": " + e.Error())
+			return
+		}
+	}
+	return
+}
+
+// splitTransform: the steps of a transform, split at each "|" a registered name follows.
+func splitTransform(transform string) (steps []string) {
+	step := ""
+	for i := 0; i < len(transform); i++ {
+		switch {
+		case strings.HasPrefix(transform[i:], `\|`):
+			step += "|"
+			i++
+		case transform[i] == '|':
+			name := transform[i+1:]
+			if j := strings.IndexAny(name, "#|"); j >= 0 {
+				name = name[:j]
+			}
+			transformsMutex.RLock()
+			_, ok := transforms[name]
+			transformsMutex.RUnlock()
+			if ok {
+				steps = append(steps, step)
+				step = ""
+			} else {
+				step += "|"
+			}
+		default:
+			step += transform[i : i+1]
+		}
+	}
+	steps = append(steps, step)
+	return
+}
+
+func init() {
+	RegisterTransform("replace", replaceParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = data
+		for _, sd := range param.([]replacePairT) {
+			dt = strings.ReplaceAll(dt, sd.src, sd.des)
+		}
+		return
+	})
+	RegisterTransform("equalto", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = "false"
+		if strings.Compare(data, param.(string)) == 0 {
+			dt = "true"
+		}
+		return
+	})
+	RegisterTransform("fill_templet", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) { // /view?idf=article&iid=[id]
+		dt = strings.Replace(param.(string), "["+ctx.Property+"]", data, -1)
+		return
+	})
+	RegisterTransform("cut_left", intParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		if maxlen := param.(int); maxlen > 0 {
+			dt = base.FirstWords(data, maxlen)
+		}
+		return
+	})
+	RegisterTransform("data_extract", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		if path := param.(string); len(data) > 0 && len(path) > 0 { //images.src
+			if gjson.Valid(data) {
+				dt = extractData(gjson.Parse(data), path)
+			}
+		}
+		return
+	})
+	RegisterTransform("date_format", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		layout := param.(string)
+		if v, ok := ctx.Session["dateformat_preferred"]; ok {
+			layout = v
+		}
+		tm, err := base.Str20time(data)
+		if err == nil {
+			if !tm.IsZero() && !base.IsZero(tm) {
+				dt = tm.Format(base.DateTimeLayout(layout))
+			}
+		}
+		return
+	})
+	RegisterTransform("datetime_format", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		layout := param.(string)
+		dateformat, timeformat := "2006-01-02", "15:04:05"
+		if v, ok := ctx.Session["dateformat_preferred"]; ok {
+			dateformat = v
+		}
+		if v, ok := ctx.Session["timeformat_preferred"]; ok {
+			timeformat = v
+		}
+		tm, err := base.Str20time(data)
+		if err == nil {
+			if layout == "humanized" {
+				clientlanguage_id := base.BaseLanguage_id()
+				if v, ok := ctx.Session["clientlanguage_id"]; ok {
+					clientlanguage_id = v
+				}
+				dt = base.HumanizedTime(tm, dateformat, timeformat, clientlanguage_id)
+			} else {
+				if len(layout) == 0 {
+					layout = dateformat + " " + timeformat
+				}
+				if !tm.IsZero() && !base.IsZero(tm) {
+					dt = tm.Format(base.DateTimeLayout(layout))
+				}
+			}
+		}
+		return
+	})
+	RegisterTransform("poplink", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = `<a href="/poplink?z=` + escAttr(base.EncodeParam(data)) + `" target="_blank">` + escText(param.(string)) + `</a>`
+		return
+	})
+	RegisterTransform("hyperlink", stringParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = `<a href="` + escURL(data) + `">` + escText(param.(string)) + `</a>`
+		return
+	})
+	RegisterTransform("LF2BR", noParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = strings.ReplaceAll(escText(data), "\n", "<br>")
+		return
+	})
+	RegisterTransform("base64", noParam, func(ctx *TransformContextT, data string, param any) (dt string, e error) {
+		dt = "bs64:" + base64.StdEncoding.EncodeToString([]byte(data))
+		return
+	})
+}
@@ -0,0 +1,52 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTransform(t *testing.T) {
+	tests := []struct {
+		transform string
+		steps     []string
+	}{
+		{"LF2BR", []string{"LF2BR"}},
+		{"data_extract#images.src|equalto#a.gif", []string{"data_extract#images.src", "equalto#a.gif"}},
+		{"replace#|/,|base64", []string{"replace#|/,", "base64"}},
+		{"replace#a|b/c;x|y/z", []string{"replace#a|b/c;x|y/z"}},
+		{`replace#equalto/x\|base64/y|LF2BR`, []string{"replace#equalto/x|base64/y", "LF2BR"}},
+		{"replace#a/b|nosuch#1", []string{"replace#a/b|nosuch#1"}},
+	}
+	for _, tt := range tests {
+		if steps := splitTransform(tt.transform); !reflect.DeepEqual(steps, tt.steps) {
+			t.Errorf("splitTransform(%q) = %q, want %q", tt.transform, steps, tt.steps)
+		}
+	}
+}
+
+func TestApplyTransform(t *testing.T) {
+	tests := []struct {
+		property, data, transform string
+		dt                        string
+	}{
+		{"title", "a|b|c", "replace#|/,", "a,b,c"},
+		{"title", "a|b", "replace#|/ or |equalto#a or b", "true"},
+		{"title", "x", `replace#x/\|`, "|"},
+		{"title", "a\nb", "LF2BR", "a<br>b"},
+		{"id", "7", "fill_templet#/view?iid=[id]", "/view?iid=7"},
+		{"images", `{"images":[{"src":"a.gif"}]}`, "data_extract#images.src|equalto#a.gif", "true"},
+		{"url", "javascript:alert(1)", "hyperlink#<b>go</b>", `<a href="#ZgotmplZ">&lt;b&gt;go&lt;/b&gt;</a>`},
+		{"title", "ab", "base64", "bs64:YWI="},
+		{"title", "ab", "", "ab"},
+	}
+	for _, tt := range tests {
+		if dt, e := ApplyTransform(tt.property, tt.data, tt.transform, ""); e != nil || dt != tt.dt {
+			t.Errorf("ApplyTransform(%q, %q) = %q %v, want %q", tt.data, tt.transform, dt, e, tt.dt)
+		}
+	}
+	for _, transform := range []string{"nosuch", "LF2BR#1", "replace#a/b|LF2BR#x"} {
+		if _, e := ApplyTransform("title", "a", transform, ""); e == nil {
+			t.Errorf("ApplyTransform(%q): no error", transform)
+		}
+	}
+}
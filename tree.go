@@ -0,0 +1,194 @@
+package object
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// TreeNodeT is a row of a hierarchical table with its children in sibling order.
+type TreeNodeT struct {
+	Row      RowT
+	Children []*TreeNodeT
+}
+
+func (repo *Repository) treeTable(tablename string) (rt *repoTableT, e error) {
+	if rt, e = repo.table(tablename); e == nil && !rt.hierarchical {
+		e = errors.New(tablename + " is not hierarchical!")
+	}
+	return
+}
+
+// InsertChild adds row under parentid (0: a root) as the last sibling unless row sets ordinalposition.
+func (repo *Repository) InsertChild(tablename string, parentid int64, row RowT) (id int64, e error) {
+	if _, e = repo.treeTable(tablename); e != nil {
+		return
+	}
+	values := RowT{}
+	for k, v := range row {
+		values[k] = v
+	}
+	values["parentid"] = parentid
+	id, e = repo.Insert(tablename, values)
+	return
+}
+
+// MoveSubtree moves id with its descendants under parentid (0: to the roots) as the last sibling.
+func (repo *Repository) MoveSubtree(tablename string, id, parentid int64) (e error) {
+	rt, err := repo.treeTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) error {
+		return repo.move(tx, rt, id, parentid)
+	})
+	return
+}
+
+// ReorderSiblings numbers the children of parentid 1..n in the order of ids, which must be all of them.
+func (repo *Repository) ReorderSiblings(tablename string, parentid int64, ids []int64) (e error) {
+	rt, err := repo.treeTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		children, err := repo.query(tx, rt, "SELECT id FROM "+repo.ident(rt.name)+" WHERE parentid="+repo.placeholder(1), parentid)
+		if err != nil {
+			return
+		}
+		if len(children) != len(ids) {
+			err = errors.New(rt.name + ": " + strconv.Itoa(len(ids)) + " ids for " + strconv.Itoa(len(children)) + " siblings!")
+			return
+		}
+		mapChild := make(map[int64]bool)
+		for _, child := range children {
+			mapChild[toInt64(child["id"])] = false
+		}
+		for i, id := range ids {
+			done, ok := mapChild[id]
+			if !ok {
+				err = errors.New(rt.name + " " + strconv.FormatInt(id, 10) + " is not a child of " + strconv.FormatInt(parentid, 10) + "!")
+				return
+			}
+			if done {
+				err = errors.New(rt.name + " " + strconv.FormatInt(id, 10) + " given twice!")
+				return
+			}
+			mapChild[id] = true
+			if _, err = tx.Exec("UPDATE "+repo.ident(rt.name)+" SET ordinalposition="+repo.placeholder(1)+" WHERE id="+repo.placeholder(2), i+1, id); err != nil {
+				return
+			}
+		}
+		return
+	})
+	return
+}
+
+// DeleteSubtree deletes id with all its descendants and their sub-object rows.
+func (repo *Repository) DeleteSubtree(tablename string, id int64) (affected int64, e error) {
+	rt, err := repo.treeTable(tablename)
+	if err != nil {
+		e = err
+		return
+	}
+	e = repo.inTx(func(tx *sql.Tx) (err error) {
+		var parentid int64
+		if err = tx.QueryRow("SELECT parentid FROM "+repo.ident(rt.name)+" WHERE id="+repo.placeholder(1), id).Scan(&parentid); err != nil {
+			return
+		}
+		ids, err := repo.descendantIDs(tx, rt, id)
+		if err != nil {
+			return
+		}
+		ids = append([]int64{id}, ids...)
+		if err = repo.deleteSubrows(tx, rt, ids); err != nil {
+			return
+		}
+		for i := len(ids) - 1; i >= 0; i-- { //leaves first
+			var n int64
+			if n, err = execSQL(tx, "DELETE FROM "+repo.ident(rt.name)+" WHERE id="+repo.placeholder(1), ids[i]); err != nil {
+				return
+			}
+			affected += n
+		}
+		err = repo.refreshLeaf(tx, rt, parentid)
+		return
+	})
+	return
+}
+
+// Ancestors: the rows from the root down to the parent of id.
+func (repo *Repository) Ancestors(tablename string, id int64) (rows []RowT, e error) {
+	if _, e = repo.treeTable(tablename); e != nil {
+		return
+	}
+	row, err := repo.Get(tablename, id)
+	if err != nil {
+		e = err
+		return
+	}
+	visited := map[int64]bool{id: true}
+	for parentid := toInt64(row["parentid"]); parentid > 0; parentid = toInt64(row["parentid"]) {
+		if visited[parentid] {
+			e = errors.New(tablename + ": parentid cycle at " + strconv.FormatInt(parentid, 10) + "!")
+			return
+		}
+		visited[parentid] = true
+		if row, e = repo.Get(tablename, parentid); e != nil {
+			return
+		}
+		rows = append([]RowT{row}, rows...)
+	}
+	return
+}
+
+// Descendants: the rows below id, depth first in sibling order.
+func (repo *Repository) Descendants(tablename string, id int64) (rows []RowT, e error) {
+	nodes, err := repo.Tree(tablename, id)
+	if err != nil {
+		e = err
+		return
+	}
+	var walk func(nodes []*TreeNodeT)
+	walk = func(nodes []*TreeNodeT) {
+		for _, node := range nodes {
+			rows = append(rows, node.Row)
+			walk(node.Children)
+		}
+	}
+	walk(nodes)
+	return
+}
+
+// Tree: the children of id as trees, id 0 gives the whole table.
+func (repo *Repository) Tree(tablename string, id int64) (nodes []*TreeNodeT, e error) {
+	if _, e = repo.treeTable(tablename); e != nil {
+		return
+	}
+	visited := map[int64]bool{id: true}
+	var children func(parentid int64) ([]*TreeNodeT, error)
+	children = func(parentid int64) (nn []*TreeNodeT, err error) {
+		rows, err := repo.List(tablename, ListOptionT{Where: RowT{"parentid": parentid}, OrderBy: []string{"ordinalposition", "id"}})
+		if err != nil {
+			return
+		}
+		for _, row := range rows {
+			child := toInt64(row["id"])
+			if visited[child] {
+				err = errors.New(tablename + ": parentid cycle at " + strconv.FormatInt(child, 10) + "!")
+				return
+			}
+			visited[child] = true
+			node := &TreeNodeT{Row: row}
+			if node.Children, err = children(child); err != nil {
+				return
+			}
+			nn = append(nn, node)
+		}
+		return
+	}
+	nodes, e = children(id)
+	return
+}
@@ -0,0 +1,131 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+// treeNames: the names of nodes depth first, children in brackets.
+func treeNames(nodes []*TreeNodeT) (s string) {
+	for i, node := range nodes {
+		if i > 0 {
+			s += ","
+		}
+		s += node.Row["name"].(string)
+		if len(node.Children) > 0 {
+			s += "[" + treeNames(node.Children) + "]"
+		}
+	}
+	return
+}
+
+func TestTree(t *testing.T) {
+	repo := openTestRepository(t, repositoryDefinition, "category")
+	insert := func(name string, parentid int64) (id int64) {
+		id, e := repo.InsertChild("category", parentid, RowT{"name": name})
+		if e != nil {
+			t.Fatalf("InsertChild %s: %v", name, e)
+		}
+		return
+	}
+	a := insert("a", 0)
+	b := insert("b", 0)
+	a1 := insert("a1", a)
+	a2 := insert("a2", a)
+	a11 := insert("a11", a1)
+	b1 := insert("b1", b)
+	item := mustInsert(t, repo, "category_item", RowT{"category_id": a11, "title": "i"})
+	mustInsert(t, repo, "category_item_part", RowT{"category_id": a11, "category_item_id": item, "label": "p"})
+
+	nodes, e := repo.Tree("category", 0)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got, want := treeNames(nodes), "a[a1[a11],a2],b[b1]"; got != want {
+		t.Errorf("Tree = %s, want %s", got, want)
+	}
+
+	if e = repo.MoveSubtree("category", a1, b); e != nil {
+		t.Fatal(e)
+	}
+	want := map[int64][4]int64{ //parentid,depth,isleaf,ordinalposition
+		a:   {0, 0, 0, 1},
+		b:   {0, 0, 0, 2},
+		a1:  {b, 1, 0, 2},
+		a2:  {a, 1, 1, 2},
+		a11: {a1, 2, 1, 1},
+		b1:  {b, 1, 1, 1},
+	}
+	if got := treeState(t, repo, "category"); !reflect.DeepEqual(got, want) {
+		t.Errorf("after MoveSubtree(a1, b) = %v, want %v", got, want)
+	}
+	if e = repo.MoveSubtree("category", a2, 0); e != nil {
+		t.Fatal(e)
+	}
+	if got, want := treeState(t, repo, "category"), [2][4]int64{{0, 0, 1, 1}, {0, 0, 1, 3}}; [2][4]int64{got[a], got[a2]} != want {
+		t.Errorf("after MoveSubtree(a2, 0): a %v a2 %v, want %v", got[a], got[a2], want)
+	}
+	for _, id := range []int64{b, a11} {
+		if e = repo.MoveSubtree("category", b, id); e == nil {
+			t.Errorf("MoveSubtree(b, %d): no error", id)
+		}
+	}
+
+	if e = repo.ReorderSiblings("category", b, []int64{a1, b1}); e != nil {
+		t.Fatal(e)
+	}
+	if nodes, e = repo.Tree("category", b); e != nil {
+		t.Fatal(e)
+	}
+	if got, want := treeNames(nodes), "a1[a11],b1"; got != want {
+		t.Errorf("Tree(b) after ReorderSiblings = %s, want %s", got, want)
+	}
+	for _, ids := range [][]int64{{a1}, {a1, a1}, {a1, a2}} {
+		if e = repo.ReorderSiblings("category", b, ids); e == nil {
+			t.Errorf("ReorderSiblings(b, %v): no error", ids)
+		}
+	}
+
+	ancestors, e := repo.Ancestors("category", a11)
+	if e != nil {
+		t.Fatal(e)
+	}
+	names := []any{}
+	for _, row := range ancestors {
+		names = append(names, row["name"])
+	}
+	if want := []any{"b", "a1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Ancestors(a11) = %v, want %v", names, want)
+	}
+	descendants, e := repo.Descendants("category", b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(descendants) != 3 {
+		t.Errorf("Descendants(b): %d rows, want 3", len(descendants))
+	}
+
+	if n, e := repo.DeleteSubtree("category", a1); e != nil || n != 2 {
+		t.Fatalf("DeleteSubtree(a1) = %d, %v", n, e)
+	}
+	want = map[int64][4]int64{
+		a:  {0, 0, 1, 1},
+		b:  {0, 0, 0, 2},
+		a2: {0, 0, 1, 3},
+		b1: {b, 1, 1, 2},
+	}
+	if got := treeState(t, repo, "category"); !reflect.DeepEqual(got, want) {
+		t.Errorf("after DeleteSubtree(a1) = %v, want %v", got, want)
+	}
+	for _, tablename := range []string{"category_item", "category_item_part"} {
+		if n, e := repo.Count(tablename, nil); e != nil || n != 0 {
+			t.Errorf("Count(%s) = %d, %v, want 0", tablename, n, e)
+		}
+	}
+	if n, e := repo.DeleteSubtree("category", b); e != nil || n != 2 {
+		t.Errorf("DeleteSubtree(b) = %d, %v", n, e)
+	}
+	if _, e = repo.Tree("category_item", 0); e == nil {
+		t.Error("Tree of a table not hierarchical: no error")
+	}
+}
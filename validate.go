@@ -0,0 +1,223 @@
+package object
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/svcbase/base"
+	"github.com/tidwall/gjson"
+)
+
+// ValidationError locates a problem by its gjson path in the definition, like "article.indexes.1.properties".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (ve ValidationError) Error() string {
+	return ve.Path + ": " + ve.Message
+}
+
+var (
+	objectTypes     = []string{"object", "object_extension", "object_relation", "codeset"}
+	propertyTypes   = []string{"int", "long", "float", "decimal", "time", "string", "password", "ipv4", "ipv6", "dotids", "text", "blob"}
+	indexTypes      = []string{"primary", "single", "composite", "unique", "fulltext", "major", "auxiliary"}
+	propertyIndexes = []string{"single", "major", "auxiliary"}
+	capacities      = []string{"M", "medium", "L", "long"}
+	identifierRegex = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]*$")
+)
+
+// ValidateDefinition parses jsontxt and validates the identifier object, see Validate.
+func ValidateDefinition(jsontxt []byte, identifier string, codesetExists func(codeset string) bool) (errs []ValidationError) {
+	var od *ObjectDefinition
+	e := errors.New("definition error!")
+	if gjson.ValidBytes(jsontxt) {
+		od, e = parseDefinitionResult(gjson.GetBytes(jsontxt, identifier), identifier) //the limit rules are reported by Validate
+	}
+	if e != nil {
+		errs = append(errs, ValidationError{identifier, e.Error()})
+	} else {
+		errs = Validate(od, codesetExists)
+	}
+	return
+}
+
+/*
+Validate checks a definition against the vocabulary extObject and property2SQL understand:
+object and property types, index types, index properties, "options"/"codeset" references, the access rules of "limit" and the properties of "permissions".
+codesetExists resolves referenced codesets (or chooser entities), nil checks the identifier syntax only.
+*/
+func Validate(od *ObjectDefinition, codesetExists func(codeset string) bool) (errs []ValidationError) {
+	errs = validateObject(od, codesetExists)
+	return
+}
+
+func validateObject(od *ObjectDefinition, codesetExists func(codeset string) bool) (errs []ValidationError) {
+	path := strings.Join(od.Roadmap, ".")
+	report := func(subpath, msg string) {
+		if len(subpath) > 0 {
+			subpath = "." + subpath
+		}
+		errs = append(errs, ValidationError{path + subpath, msg})
+	}
+	if exists, _ := base.In_array(od.Type, objectTypes); !exists {
+		report("type", "unknown object type: "+od.Type)
+	}
+	switch od.Type {
+	case "object_extension":
+		if len(od.Extension) == 0 {
+			report("", "object_extension without extension")
+		}
+	case "object_relation":
+		if len(od.Relation) == 0 {
+			report("", "object_relation without relation")
+		} else if !identifierRegex.MatchString(od.Relation) {
+			report("relation", "invalid identifier: "+od.Relation)
+		}
+	}
+	if len(od.SelfRelationship) > 0 && od.SelfRelationship != "hierarchical" {
+		report("self_relationship", "unknown self_relationship: "+od.SelfRelationship)
+	}
+	if len(od.Language) > 0 && od.Language != "single" && od.Language != "multiple" {
+		report("language", "unknown language: "+od.Language)
+	}
+	implicit := implicitProperties(od)
+	for _, pd := range od.Properties {
+		isImplicit, _ := base.In_array(pd.Name, implicit)
+		if len(pd.Type) == 0 {
+			if !isImplicit {
+				report(pd.Name, "property without type")
+			}
+		} else if pd.Type == "codeset" { //parseObject takes a nested codeset for a property
+			report(pd.Name+".type", "codeset must not be in second level")
+		} else if exists, _ := base.In_array(pd.Type, propertyTypes); !exists {
+			report(pd.Name+".type", "unknown property type: "+pd.Type)
+		}
+		if len(pd.Index) > 0 {
+			if exists, _ := base.In_array(pd.Index, propertyIndexes); !exists {
+				report(pd.Name+".index", "unknown property index: "+pd.Index)
+			}
+		}
+		if len(pd.Size) > 0 && base.Str2int(pd.Size) <= 0 {
+			report(pd.Name+".size", "size must be a positive number")
+		}
+		if len(pd.DecimalPlaces) > 0 && !base.IsDigital(pd.DecimalPlaces) {
+			report(pd.Name+".decimal_places", "decimal_places must be a number")
+		}
+		if len(pd.Capacity) > 0 {
+			if exists, _ := base.In_array(pd.Capacity, capacities); !exists {
+				report(pd.Name+".capacity", "unknown capacity: "+pd.Capacity)
+			}
+		}
+		if len(pd.Pattern) > 0 {
+			if _, err := regexp.Compile(pd.Pattern); err != nil {
+				report(pd.Name+".pattern", err.Error())
+			}
+		}
+		for _, key := range []string{"options", "codeset"} {
+			ref := pd.Options
+			if key == "codeset" {
+				ref = pd.Codeset
+			}
+			if len(ref) > 0 {
+				if !identifierRegex.MatchString(ref) {
+					report(pd.Name+"."+key, "invalid identifier: "+ref)
+				} else if codesetExists != nil && !codesetExists(ref) {
+					report(pd.Name+"."+key, "unknown codeset or object: "+ref)
+				}
+			}
+		}
+	}
+	names := append([]string{}, implicit...)
+	for _, pd := range od.Properties {
+		names = append(names, pd.Name)
+	}
+	for i, idx := range od.Indexes {
+		ipath := "indexes." + strconv.Itoa(i)
+		if len(idx.Name) == 0 {
+			report(ipath+".name", "index without name")
+		}
+		if len(idx.Type) > 0 {
+			if exists, _ := base.In_array(idx.Type, indexTypes); !exists {
+				report(ipath+".type", "unknown index type: "+idx.Type)
+			}
+		}
+		pp := idx.PropertyNames()
+		if len(pp) == 0 {
+			report(ipath+".properties", "index without properties")
+		}
+		for _, p := range pp {
+			if exists, _ := base.In_array(p, names); !exists {
+				report(ipath+".properties", "unknown property: "+p)
+			}
+		}
+	}
+	if raw, ok := od.raw["limit"]; ok {
+		if limit := gjson.Parse(raw); !limit.IsArray() {
+			report("limit", "limit must be an array")
+		} else {
+			for i, row := range limit.Array() {
+				lpath := "limit." + strconv.Itoa(i) + ".access"
+				rule, err := ParseAccessRule(row.Get("access").String())
+				if err != nil {
+					report(lpath, err.Error())
+					continue
+				}
+				for _, p := range rule.Properties() {
+					if exists, _ := base.In_array(p, names); !exists {
+						report(lpath, "unknown property: "+p)
+					}
+				}
+			}
+		}
+	}
+	if raw, ok := od.raw["permissions"]; ok {
+		if permissions := gjson.Parse(raw); !permissions.IsArray() {
+			report("permissions", "permissions must be an array")
+		} else {
+			for i, row := range permissions.Array() {
+				ppath := "permissions." + strconv.Itoa(i)
+				if len(splitList(row.Get("usertype").String())) == 0 {
+					report(ppath+".usertype", "permission without usertype")
+				}
+				for _, key := range []string{"visible", "editable"} {
+					for _, p := range splitList(row.Get(key).String()) {
+						if exists, _ := base.In_array(p, names); !exists && p != "*" {
+							report(ppath+"."+key, "unknown property: "+p)
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, sub := range od.Objects {
+		errs = append(errs, validateObject(sub, codesetExists)...)
+	}
+	return
+}
+
+// implicitProperties: the properties extObject adds to every extended object.
+func implicitProperties(od *ObjectDefinition) (names []string) {
+	names = []string{"id", "time_created", "time_updated"}
+	nHier := len(od.Roadmap)
+	if nHier > 1 && od.Type != "object_extension" {
+		for i := 0; i < nHier-1; i++ {
+			names = append(names, strings.Join(od.Roadmap[0:i+1], "_")+"_id")
+		}
+	}
+	if len(od.Extension) > 0 {
+		names = append(names, od.Extension+"_id", od.Extension+"_tag")
+	}
+	if len(od.Relation) > 0 {
+		names = append(names, od.Relation+"_id")
+	}
+	if od.Type == "codeset" {
+		names = append(names, "code", "name", "description", "enableflag", "ordinalposition", "occurrences")
+	}
+	if od.SelfRelationship == "hierarchical" {
+		names = append(names, "parentid", "ordinalposition", "isleaf", "depth")
+	}
+	return
+}
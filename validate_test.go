@@ -0,0 +1,103 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateDefinition(t *testing.T) {
+	codesets := map[string]bool{"region": true, "user": true}
+	codesetExists := func(codeset string) bool { return codesets[codeset] }
+	tests := []struct {
+		name       string
+		definition string
+		errs       []string
+	}{
+		{"valid", `{"type":"object","self_relationship":"hierarchical","id":{"type":"int"},"title":{"type":"string","size":50,"index":"single"},
+			"region_id":{"type":"int","options":"region"},"price":{"type":"decimal","size":10,"decimal_places":2},"body":{"type":"text","capacity":"M"},
+			"chapter":{"type":"object","title":{"type":"string"},"indexes":[{"name":"book","properties":"article_id,title desc"}]},
+			"indexes":[{"name":"pk","type":"primary","properties":"id"},{"name":"tree","properties":"parentid,ordinalposition"}],
+			"limit":[{"usertype":"all","access":"region_id=session@region_id OR depth<2"}],
+			"permissions":[{"usertype":"editor","visible":"*","editable":"title,price"}]}`, nil},
+		{"types", `{"type":"object","self_relationship":"flat","language":"many","a":{"type":"varchar"},"b":{"size":5}}`, []string{
+			"article.self_relationship: unknown self_relationship: flat",
+			"article.language: unknown language: many",
+			"article.a.type: unknown property type: varchar",
+			"article.b: property without type",
+		}},
+		{"properties", `{"type":"object","a":{"type":"string","size":"0","index":"primary"},"b":{"type":"decimal","decimal_places":"x"},
+			"c":{"type":"text","capacity":"huge"},"d":{"type":"string","pattern":"[a-"},"e":{"type":"int","options":"nosuch"},"f":{"type":"string","codeset":"bad-name"}}`, []string{
+			"article.a.index: unknown property index: primary",
+			"article.a.size: size must be a positive number",
+			"article.b.decimal_places: decimal_places must be a number",
+			"article.c.capacity: unknown capacity: huge",
+			"article.d.pattern: error parsing regexp: missing closing ]: `[a-`",
+			"article.e.options: unknown codeset or object: nosuch",
+			"article.f.codeset: invalid identifier: bad-name",
+		}},
+		{"second level codeset", `{"type":"object","color":{"type":"codeset","code":{"type":"string"}}}`, []string{
+			"article.color.type: codeset must not be in second level",
+		}},
+		{"relation and extension", `{"type":"object","tag":{"type":"object_relation"},"lang":{"type":"object_extension"},"link":{"type":"object_relation","relation":"a-b"}}`, []string{
+			"article.tag: object_relation without relation",
+			"article.lang: object_extension without extension",
+			"article.link.relation: invalid identifier: a-b",
+		}},
+		{"indexes", `{"type":"object","title":{"type":"string"},
+			"indexes":[{"properties":"title"},{"name":"x","type":"hash","properties":"title"},{"name":"y","properties":" "},{"name":"z","properties":"title,nosuch asc"}],
+			"chapter":{"type":"object","indexes":[{"name":"a","properties":"article_id,book_id"}]}}`, []string{
+			"article.indexes.0.name: index without name",
+			"article.indexes.1.type: unknown index type: hash",
+			"article.indexes.2.properties: index without properties",
+			"article.indexes.3.properties: unknown property: nosuch",
+			"article.chapter.indexes.0.properties: unknown property: book_id",
+		}},
+		{"limit", `{"type":"object","title":{"type":"string"},"limit":[{"usertype":"all","access":"title='x' AND nosuch=1"},{"usertype":"guest","access":"title="}]}`, []string{
+			"article.limit.0.access: unknown property: nosuch",
+			"article.limit.1.access: " + mustAccessRuleError(t, "title="),
+		}},
+		{"limit not array", `{"type":"object","limit":"*"}`, []string{"article.limit: limit must be an array"}},
+		{"permissions", `{"type":"object","title":{"type":"string"},"permissions":[{"visible":"title"},{"usertype":"editor","visible":"title,nosuch","editable":"*,id,other"}]}`, []string{
+			"article.permissions.0.usertype: permission without usertype",
+			"article.permissions.1.visible: unknown property: nosuch",
+			"article.permissions.1.editable: unknown property: other",
+		}},
+	}
+	for _, tt := range tests {
+		errs := []string{}
+		for _, ve := range ValidateDefinition([]byte(`{"article":`+tt.definition+`}`), "article", codesetExists) {
+			errs = append(errs, ve.Error())
+		}
+		if len(errs) == 0 {
+			errs = nil
+		}
+		if !reflect.DeepEqual(errs, tt.errs) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.name, errs, tt.errs)
+		}
+	}
+	od, e := ParseDefinition([]byte(`{"article":{"type":"object"}}`), "article")
+	if e != nil {
+		t.Fatal(e)
+	}
+	od.Type = "thing"
+	if errs := Validate(od, nil); len(errs) != 1 || errs[0].Error() != "article.type: unknown object type: thing" {
+		t.Errorf("object type: %v", errs)
+	}
+	errs := ValidateDefinition([]byte(`{"article":{"type":"object","e":{"type":"int","options":"nosuch"}}}`), "article", nil)
+	if len(errs) != 0 {
+		t.Errorf("nil codesetExists: %v", errs)
+	}
+	errs = ValidateDefinition([]byte(`{"article":{"type":"object"}}`), "book", nil)
+	if len(errs) != 1 || errs[0].Path != "book" {
+		t.Errorf("missing identifier: %v", errs)
+	}
+}
+
+func mustAccessRuleError(t *testing.T, txt string) string {
+	t.Helper()
+	_, e := ParseAccessRule(txt)
+	if e == nil {
+		t.Fatalf("ParseAccessRule(%q): no error", txt)
+	}
+	return e.Error()
+}